@@ -0,0 +1,237 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"context"
+	"time"
+	"unsafe"
+)
+
+// Bounds of the backoff between two calls of a function that returned
+// SQL_STILL_EXECUTING.
+const (
+	POLL_MIN_INTERVAL = time.Millisecond
+	POLL_MAX_INTERVAL = 100 * time.Millisecond
+)
+
+// poll calls f until it returns something other than SQL_STILL_EXECUTING,
+// sleeping with exponential backoff in between so that no OS thread is
+// held while the driver works. If ctx is done first, cancel is called
+// once and polling goes on until the driver reports the cancellation.
+// In synchronous mode f never returns SQL_STILL_EXECUTING and poll is a
+// plain call.
+func poll(ctx context.Context, f func() C.SQLRETURN, cancel func()) C.SQLRETURN {
+	ret := f()
+	if ret != C.SQL_STILL_EXECUTING {
+		return ret
+	}
+	done := ctx.Done()
+	delay := POLL_MIN_INTERVAL
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for ret == C.SQL_STILL_EXECUTING {
+		select {
+		case <-done:
+			cancel()
+			done = nil
+			continue
+		case <-timer.C:
+		}
+		ret = f()
+		if delay *= 2; delay > POLL_MAX_INTERVAL {
+			delay = POLL_MAX_INTERVAL
+		}
+		timer.Reset(delay)
+	}
+	return ret
+}
+
+// wait polls f on behalf of a statement without a deadline.
+func (stmt *Statement) wait(f func() C.SQLRETURN) C.SQLRETURN {
+	return poll(context.Background(), f, stmt.cancel)
+}
+
+// cmalloc allocates n zeroed bytes of C memory, to be released with
+// C.free. The buffers passed to a function polled by wait or poll must
+// be in C memory: in asynchronous mode the driver keeps their addresses
+// and writes them in a later poll, after the call that passed them has
+// returned, which cgo does not allow for Go memory.
+func cmalloc(n uintptr) unsafe.Pointer {
+	if n < 1 {
+		n = 1
+	}
+	return C.calloc(1, C.size_t(n))
+}
+
+func (stmt *Statement) cancel() {
+	C.SQLCancel(C.SQLHSTMT(stmt.handle))
+}
+
+// SetAsync turns asynchronous execution on or off for all statements
+// allocated afterwards on the connection.
+func (conn *Connection) SetAsync(b bool) *ODBCError {
+	var n uintptr = C.SQL_ASYNC_ENABLE_OFF
+	if b {
+		n = C.SQL_ASYNC_ENABLE_ON
+	}
+	ret := C.SQLSetConnectAttr(C.SQLHDBC(conn.Dbc), C.SQL_ATTR_ASYNC_ENABLE, C.SQLPOINTER(unsafe.Pointer(n)), C.SQL_IS_UINTEGER)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return err
+	}
+	return nil
+}
+
+// SetAsync turns asynchronous execution on or off for the statement.
+// In asynchronous mode Execute, ExecDirect and Fetch poll the driver
+// instead of blocking, and their Context variants can be cancelled.
+func (stmt *Statement) SetAsync(b bool) *ODBCError {
+	var n uintptr = C.SQL_ASYNC_ENABLE_OFF
+	if b {
+		n = C.SQL_ASYNC_ENABLE_ON
+	}
+	return stmt.setAttr(C.SQL_ATTR_ASYNC_ENABLE, n)
+}
+
+// ConnectAsync is like Connect, but connects with asynchronous
+// connection functions enabled and turns on asynchronous execution for
+// the statements of the returned connection. The connection attempt is
+// cancelled when ctx is done. Asynchronous connection functions are
+// turned off again once connected, so that Commit, Rollback and Close
+// do not need polling.
+func ConnectAsync(ctx context.Context, dsn string) (*Connection, *ODBCError) {
+	conn, err := NewConnection()
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetAttr(C.SQL_ATTR_ASYNC_DBC_FUNCTIONS_ENABLE, C.SQL_ASYNC_DBC_ENABLE_ON); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	h := conn.Dbc
+
+	// The driver may use the buffers between two polls, keep them in C memory.
+	inConnectionString := C.CBytes(stringToWide(dsn))
+	defer C.free(inConnectionString)
+	outConnectionString := C.malloc(C.size_t(BUFFER_SIZE * wcharSize))
+	defer C.free(outConnectionString)
+	stringLength2 := (*C.SQLSMALLINT)(C.malloc(C.size_t(unsafe.Sizeof(C.SQLSMALLINT(0)))))
+	defer C.free(unsafe.Pointer(stringLength2))
+
+	ret := poll(ctx, func() C.SQLRETURN {
+		return C.SQLDriverConnectW(C.SQLHDBC(h),
+			C.SQLHWND(unsafe.Pointer(uintptr(0))),
+			(*C.SQLWCHAR)(inConnectionString),
+			C.SQL_NTS,
+			(*C.SQLWCHAR)(outConnectionString),
+			BUFFER_SIZE,
+			stringLength2,
+			C.SQL_DRIVER_NOPROMPT)
+	}, func() {
+		C.SQLCancelHandle(C.SQL_HANDLE_DBC, h)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DBC, h)
+		conn.Close()
+		return nil, err
+	}
+	conn.connected = true
+	conn.checkInfo(ret)
+	if err := conn.SetAttr(C.SQL_ATTR_ASYNC_DBC_FUNCTIONS_ENABLE, C.SQL_ASYNC_DBC_ENABLE_OFF); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetAsync(true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (conn *Connection) ExecDirectContext(ctx context.Context, sql string) (stmt *Statement, err *ODBCError) {
+	if stmt, err = conn.newStmt(); err != nil {
+		return nil, err
+	}
+	if err = stmt.ExecDirectContext(ctx, sql); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// ExecDirectContext is like ExecDirect, but cancels the statement when
+// ctx is done. Cancellation only takes effect in asynchronous mode.
+func (stmt *Statement) ExecDirectContext(ctx context.Context, sql string) *ODBCError {
+	exec := func(csql unsafe.Pointer) C.SQLRETURN {
+		return C.SQLExecDirectW(C.SQLHSTMT(stmt.handle), (*C.SQLWCHAR)(csql), C.SQL_NTS)
+	}
+	b := stringToWide(sql)
+	if stmt.enc != nil {
+		var err *ODBCError
+		if b, err = encode(stmt.enc, sql); err != nil {
+			return err
+		}
+		exec = func(csql unsafe.Pointer) C.SQLRETURN {
+			return C.SQLExecDirect(C.SQLHSTMT(stmt.handle), (*C.SQLCHAR)(csql), C.SQL_NTS)
+		}
+	}
+	csql := C.CBytes(b)
+	defer C.free(csql)
+	stmt.warnings = nil
+	stmt.fields = nil
+	ret := poll(ctx, func() C.SQLRETURN {
+		return exec(csql)
+	}, stmt.cancel)
+	stmt.checkInfo(ret)
+	if ret == C.SQL_NO_DATA {
+		// Execute NO DATA
+	} else if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	stmt.executed = true
+	return nil
+}
+
+// ExecuteContext is like Execute, but cancels the statement when ctx is
+// done. Cancellation only takes effect in asynchronous mode.
+func (stmt *Statement) ExecuteContext(ctx context.Context, params ...interface{}) *ODBCError {
+	if err := stmt.bindParams(params); err != nil {
+		return err
+	}
+	return stmt.execute(ctx)
+}
+
+// FetchContext is like Fetch, but cancels the statement when ctx is
+// done. Cancellation only takes effect in asynchronous mode.
+func (stmt *Statement) FetchContext(ctx context.Context) (bool, *ODBCError) {
+	ret := poll(ctx, func() C.SQLRETURN {
+		return C.SQLFetch(C.SQLHSTMT(stmt.handle))
+	}, stmt.cancel)
+	stmt.checkInfo(ret)
+	if ret == C.SQL_NO_DATA {
+		return false, nil
+	}
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return false, err
+	}
+	return true, nil
+}
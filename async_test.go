@@ -0,0 +1,180 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func asyncStmt(t *testing.T, conn *Connection) *Statement {
+	t.Helper()
+	stmt, err := conn.NewStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stmt.Close)
+	if err := stmt.SetAsync(true); err != nil {
+		t.Skip("no asynchronous execution: ", err)
+	}
+	return stmt
+}
+
+// TestAsyncStatement goes through the calls that poll in asynchronous
+// mode, collecting garbage in between so that buffers the driver writes
+// in a later poll are caught by cgocheck2 if they are Go memory.
+func TestAsyncStatement(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_async", "id integer, name varchar(20), amount double, data varbinary(20)")
+	testExec(t, conn, "insert into odbc_async values (?, ?, ?, ?)", 1, "one", 1.5, []byte{1, 2, 3})
+	testExec(t, conn, "insert into odbc_async values (?, ?, ?, ?)", 2, "two", nil, nil)
+
+	stmt := asyncStmt(t, conn)
+	if err := stmt.Prepare("select id, name, amount, data from odbc_async where id >= ? order by id"); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	if n := stmt.NumParams(); n != 1 {
+		t.Fatalf("NumParams = %d, want 1", n)
+	}
+	if err := stmt.Execute(0); err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	fields, err := stmt.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 4 || fields[1].Name != "name" {
+		t.Fatalf("columns %+v", fields)
+	}
+	rows, err := stmt.FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	var (
+		id     int
+		name   *string
+		amount *float64
+		data   []byte
+	)
+	if err := rows[0].Scan(&id, &name, &amount, &data); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || name == nil || *name != "one" || amount == nil || *amount != 1.5 || string(data) != "\x01\x02\x03" {
+		t.Errorf("row 1 is (%v, %v, %v, %v)", id, name, amount, data)
+	}
+	if err := rows[1].Scan(&id, &name, &amount, &data); err != nil {
+		t.Fatal(err)
+	}
+	if id != 2 || name == nil || *name != "two" || amount != nil || data != nil {
+		t.Errorf("row 2 is (%v, %v, %v, %v)", id, name, amount, data)
+	}
+}
+
+func TestAsyncExecDirectContext(t *testing.T) {
+	conn := testConn(t)
+	stmt := asyncStmt(t, conn)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := stmt.ExecDirectContext(ctx, "select 42"); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := stmt.FetchContext(ctx)
+	if err != nil || !ok {
+		t.Fatalf("FetchContext = %v, %v", ok, err)
+	}
+	v, _, _, err := stmt.GetField(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := v.(int); n != 42 {
+		t.Errorf("got %#v, want 42", v)
+	}
+}
+
+func TestConnectAsync(t *testing.T) {
+	testConn(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	conn, err := ConnectAsync(ctx, os.Getenv("ODBC_TEST_DSN"))
+	if err != nil {
+		t.Skip("no asynchronous connection: ", err)
+	}
+	defer conn.Close()
+	stmt, err := conn.ExecDirectContext(ctx, "select 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	row, err := stmt.FetchOne()
+	if err != nil || row == nil {
+		t.Fatalf("FetchOne = %v, %v", row, err)
+	}
+}
+
+// TestConnectAsyncCommit ends a transaction and closes a connection made
+// by ConnectAsync, which would fail with HY010 if the connection
+// functions were still asynchronous.
+func TestConnectAsyncCommit(t *testing.T) {
+	check := testConn(t)
+	testTable(t, check, "odbc_async_tx", "id integer")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	conn, err := ConnectAsync(ctx, os.Getenv("ODBC_TEST_DSN"))
+	if err != nil {
+		t.Skip("no asynchronous connection: ", err)
+	}
+	if err := conn.AutoCommit(false); err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	stmt, err := conn.ExecDirectContext(ctx, "insert into odbc_async_tx values (1)")
+	if err != nil {
+		conn.Close()
+		t.Fatal(err)
+	}
+	stmt.Close()
+	if err := conn.Commit(); err != nil {
+		t.Error("Commit: ", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Error("Close: ", err)
+	}
+	if n := testCount(t, check, "odbc_async_tx"); n != 1 {
+		t.Errorf("%d rows committed, want 1", n)
+	}
+}
+
+// TestAsyncLongString binds a string longer than the largest WVARCHAR,
+// read on first use, on a connection in asynchronous mode.
+func TestAsyncLongString(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_long", "s text")
+	if err := conn.SetAsync(true); err != nil {
+		t.Skip("no asynchronous execution: ", err)
+	}
+	n := conn.wvarcharMax() + 1
+	testExec(t, conn, "insert into odbc_long values (?)", strings.Repeat("x", n))
+	stmt, err := conn.ExecDirect("select length(s) from odbc_long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	row, err := stmt.FetchOne()
+	if err != nil || row == nil {
+		t.Fatalf("FetchOne = %v, %v", row, err)
+	}
+	if got, _ := row.Int(0); got != int64(n) {
+		t.Errorf("stored %d characters, want %d", got, n)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+
+// SetUseBookmarks turns variable-length bookmarks on or off. It must be
+// called before the statement is prepared or executed, see
+// Connection.NewStatement.
+func (stmt *Statement) SetUseBookmarks(b bool) *ODBCError {
+	var n uintptr = C.SQL_UB_OFF
+	if b {
+		n = C.SQL_UB_VARIABLE
+	}
+	return stmt.setAttr(C.SQL_ATTR_USE_BOOKMARKS, n)
+}
+
+// Bookmark returns the bookmark of the current row. Drivers may require
+// it to be read before any GetField call on the row.
+func (stmt *Statement) Bookmark() ([]byte, *ODBCError) {
+	// The bookmark is column 0; ask for its length first.
+	fl := &stmt.getDataBuffer().ind
+	_, ret := stmt.getVarData(-1, C.SQL_C_VARBOOKMARK, 0, fl)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return nil, err
+	}
+	if *fl <= 0 {
+		return nil, nil
+	}
+	value, ret := stmt.getVarData(-1, C.SQL_C_VARBOOKMARK, int(*fl), fl)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return nil, err
+	}
+	return value, nil
+}
+
+// FetchBookmark positions the cursor on the row identified by bookmark,
+// as returned by Bookmark. It returns false if the row no longer exists.
+func (stmt *Statement) FetchBookmark(bookmark []byte) (bool, *ODBCError) {
+	if stmt.bookmark != nil {
+		C.free(stmt.bookmark)
+	}
+	stmt.bookmark = C.CBytes(bookmark)
+	if err := stmt.setAttr(C.SQL_ATTR_FETCH_BOOKMARK_PTR, uintptr(stmt.bookmark)); err != nil {
+		return false, err
+	}
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLFetchScroll(C.SQLHSTMT(stmt.handle), C.SQL_FETCH_BOOKMARK, 0)
+	})
+	if ret == C.SQL_NO_DATA {
+		return false, nil
+	}
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return false, err
+	}
+	return true, nil
+}
+
+func (stmt *Statement) bulkOperation(operation C.SQLSMALLINT, bookmark []byte, values map[int]interface{}) *ODBCError {
+	release, err := stmt.bindColumns(bookmark, values)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLBulkOperations(C.SQLHSTMT(stmt.handle), operation)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	return nil
+}
+
+// AddRow inserts a new row through the cursor with SQLBulkOperations.
+// values maps column index (as for GetField) to the value; columns not
+// present get their default value.
+func (stmt *Statement) AddRow(values map[int]interface{}) *ODBCError {
+	return stmt.bulkOperation(C.SQL_ADD, nil, values)
+}
+
+// UpdateByBookmark updates the row identified by bookmark. Columns not
+// present in values are left unchanged.
+func (stmt *Statement) UpdateByBookmark(bookmark []byte, values map[int]interface{}) *ODBCError {
+	return stmt.bulkOperation(C.SQL_UPDATE_BY_BOOKMARK, bookmark, values)
+}
+
+// DeleteByBookmark deletes the row identified by bookmark.
+func (stmt *Statement) DeleteByBookmark(bookmark []byte) *ODBCError {
+	return stmt.bulkOperation(C.SQL_DELETE_BY_BOOKMARK, bookmark, nil)
+}
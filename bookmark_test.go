@@ -0,0 +1,63 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	conn := testCursorTable(t)
+	stmt, err := conn.NewStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	err = stmt.SetUseBookmarks(true)
+	testUnsupported(t, "bookmarks", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stmt.SetCursorType(CURSOR_STATIC)
+	testUnsupported(t, "static cursor", err)
+	if err != nil && err.SQLState != "01S02" {
+		t.Fatal(err)
+	}
+	if err := stmt.ExecDirect("select id, name from odbc_cursor order by id"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := stmt.Fetch(); !ok || err != nil {
+		t.Fatalf("Fetch = %v, %v", ok, err)
+	}
+	bm, err := stmt.Bookmark()
+	testUnsupported(t, "bookmark", err)
+	if err != nil || len(bm) == 0 {
+		t.Fatalf("Bookmark = %v, %v", bm, err)
+	}
+	if ok, err := stmt.Fetch(); !ok || err != nil {
+		t.Fatalf("Fetch = %v, %v", ok, err)
+	}
+	if v, _, _, err := stmt.GetField(0); err != nil || v != 2 {
+		t.Fatalf("second row has id %v, %v", v, err)
+	}
+	ok, err := stmt.FetchBookmark(bm)
+	testUnsupported(t, "fetch by bookmark", err)
+	if !ok || err != nil {
+		t.Fatalf("FetchBookmark = %v, %v", ok, err)
+	}
+	if v, _, _, err := stmt.GetField(0); err != nil || v != 1 {
+		t.Errorf("bookmarked row has id %v, %v, want 1", v, err)
+	}
+
+	err = stmt.AddRow(map[int]interface{}{0: int64(4), 1: "dan"})
+	testUnsupported(t, "bulk operations", err)
+	if err != nil {
+		t.Fatal("AddRow: ", err)
+	}
+	stmt.Rows().Close()
+	if n := testCount(t, conn, "odbc_cursor where id = 4 and name = 'dan'"); n != 1 {
+		t.Error("row not added")
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+#include <string.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// cValue is a Go value copied into C memory, suitable for calls such as
+// SQLBindCol where the driver keeps the buffer address past the call.
+type cValue struct {
+	cType   C.SQLSMALLINT
+	sqlType C.SQLSMALLINT
+	size    C.SQLULEN
+	digits  C.SQLSMALLINT
+	buf     unsafe.Pointer
+	buflen  C.SQLLEN
+	ind     *C.SQLLEN
+}
+
+// newCValue copies param into C memory. Strings are SQL_C_WCHAR if wide,
+// otherwise SQL_C_CHAR converted with enc, see encode.
+func newCValue(param interface{}, enc Encoding, wide bool) (*cValue, *ODBCError) {
+	param, err := indirect(param)
+	if err != nil {
+		return nil, err
+	}
+	cv := &cValue{}
+	cv.ind = (*C.SQLLEN)(C.malloc(C.size_t(unsafe.Sizeof(C.SQLLEN(0)))))
+	if param == nil {
+		cv.cType = C.SQL_C_CHAR
+		cv.sqlType = C.SQL_VARCHAR
+		cv.size = 1
+		cv.alloc(1)
+		*cv.ind = C.SQL_NULL_DATA
+		return cv, nil
+	}
+	switch v := param.(type) {
+	case []byte:
+		cv.cType = C.SQL_C_BINARY
+		cv.sqlType = C.SQL_VARBINARY
+		cv.size = C.SQLULEN(len(v))
+		cv.alloc(len(v))
+		copyToC(cv.buf, v)
+		*cv.ind = C.SQLLEN(len(v))
+		return cv, nil
+	case time.Time:
+		cv.cType = C.SQL_C_TYPE_TIMESTAMP
+		cv.sqlType = C.SQL_TYPE_TIMESTAMP
+		cv.size = 23
+		cv.digits = 3
+		cv.alloc(int(unsafe.Sizeof(C.SQL_TIMESTAMP_STRUCT{})))
+		ts := (*C.SQL_TIMESTAMP_STRUCT)(cv.buf)
+		ts.year = C.SQLSMALLINT(v.Year())
+		ts.month = C.SQLUSMALLINT(v.Month())
+		ts.day = C.SQLUSMALLINT(v.Day())
+		ts.hour = C.SQLUSMALLINT(v.Hour())
+		ts.minute = C.SQLUSMALLINT(v.Minute())
+		ts.second = C.SQLUSMALLINT(v.Second())
+		ts.fraction = C.SQLUINTEGER(v.Nanosecond() / 1e6 * 1e6)
+		*cv.ind = 0
+		return cv, nil
+	}
+	v := reflect.ValueOf(param)
+	switch v.Kind() {
+	case reflect.Bool:
+		cv.cType = C.SQL_C_BIT
+		cv.sqlType = C.SQL_BIT
+		cv.alloc(1)
+		if v.Bool() {
+			*(*C.SQLCHAR)(cv.buf) = 1
+		}
+		*cv.ind = 0
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		var n int64
+		if v.Kind() == reflect.Uint8 || v.Kind() == reflect.Uint16 {
+			n = int64(v.Uint())
+		} else {
+			n = v.Int()
+		}
+		cv.cType = C.SQL_C_LONG
+		cv.sqlType = C.SQL_INTEGER
+		cv.alloc(4)
+		*(*C.SQLINTEGER)(cv.buf) = C.SQLINTEGER(n)
+		*cv.ind = 0
+	case reflect.Int, reflect.Int64:
+		cv.cType = C.SQL_C_SBIGINT
+		cv.sqlType = C.SQL_BIGINT
+		cv.alloc(8)
+		*(*C.SQLBIGINT)(cv.buf) = C.SQLBIGINT(v.Int())
+		*cv.ind = 0
+	case reflect.Uint32, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > math.MaxInt64 {
+			cv.free()
+			return nil, &ODBCError{SQLState: "22003", ErrorMessage: fmt.Sprintf("%T value %d overflows BIGINT", param, n)}
+		}
+		cv.cType = C.SQL_C_SBIGINT
+		cv.sqlType = C.SQL_BIGINT
+		cv.alloc(8)
+		*(*C.SQLBIGINT)(cv.buf) = C.SQLBIGINT(n)
+		*cv.ind = 0
+	case reflect.Float32, reflect.Float64:
+		cv.cType = C.SQL_C_DOUBLE
+		cv.sqlType = C.SQL_DOUBLE
+		cv.alloc(8)
+		*(*C.SQLDOUBLE)(cv.buf) = C.SQLDOUBLE(v.Float())
+		*cv.ind = 0
+	case reflect.String:
+		if wide {
+			w := stringToWide(v.String())
+			n := len(w)/wcharSize - 1
+			cv.cType = C.SQL_C_WCHAR
+			cv.sqlType = C.SQL_WVARCHAR
+			cv.size = C.SQLULEN(n)
+			if n == 0 {
+				cv.size = 1
+			}
+			cv.alloc(len(w))
+			copyToC(cv.buf, w)
+			*cv.ind = C.SQLLEN(n * wcharSize)
+			break
+		}
+		s, err := encode(enc, v.String())
+		if err != nil {
+			cv.free()
+			return nil, err
+		}
+		cv.cType = C.SQL_C_CHAR
+		cv.sqlType = C.SQL_VARCHAR
+		cv.size = C.SQLULEN(len(s) - 1)
+		cv.alloc(len(s))
+		copyToC(cv.buf, s)
+		*cv.ind = C.SQLLEN(len(s) - 1)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			cv.free()
+			return nil, unsupportedType(param)
+		}
+		b := v.Bytes()
+		cv.cType = C.SQL_C_BINARY
+		cv.sqlType = C.SQL_VARBINARY
+		cv.size = C.SQLULEN(len(b))
+		cv.alloc(len(b))
+		copyToC(cv.buf, b)
+		*cv.ind = C.SQLLEN(len(b))
+	default:
+		cv.free()
+		return nil, unsupportedType(param)
+	}
+	return cv, nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// indirect resolves driver.Valuer implementations, such as the sql.Null*
+// types, and pointers to the value they refer to. Nil pointers are nil.
+func indirect(param interface{}) (interface{}, *ODBCError) {
+	for param != nil {
+		v := reflect.ValueOf(param)
+		if vr, ok := param.(driver.Valuer); ok {
+			// A nil pointer whose type only has the method by value.
+			if v.Kind() == reflect.Ptr && v.IsNil() && v.Type().Elem().Implements(valuerType) {
+				return nil, nil
+			}
+			value, err := vr.Value()
+			if err != nil {
+				return nil, &ODBCError{SQLState: "HY000", ErrorMessage: err.Error()}
+			}
+			if _, ok := value.(driver.Valuer); ok && reflect.TypeOf(value) == v.Type() {
+				return nil, unsupportedType(param)
+			}
+			param = value
+			continue
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		param = v.Elem().Interface()
+	}
+	return param, nil
+}
+
+func unsupportedType(param interface{}) *ODBCError {
+	return &ODBCError{SQLState: "HY004", ErrorMessage: fmt.Sprintf("unsupported type %T", param)}
+}
+
+// alloc allocates a zeroed buffer of n bytes, at least one byte long so
+// that the driver always receives a valid address.
+func (cv *cValue) alloc(n int) {
+	if n < 1 {
+		n = 1
+	}
+	cv.buf = C.calloc(1, C.size_t(n))
+	cv.buflen = C.SQLLEN(n)
+}
+
+func (cv *cValue) free() {
+	if cv.buf != nil {
+		C.free(cv.buf)
+		cv.buf = nil
+	}
+	if cv.ind != nil {
+		C.free(unsafe.Pointer(cv.ind))
+		cv.ind = nil
+	}
+}
+
+func copyToC(dst unsafe.Pointer, b []byte) {
+	if len(b) > 0 {
+		C.memcpy(dst, unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	}
+}
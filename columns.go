@@ -0,0 +1,137 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+
+SQLRETURN _SQLColAttribute (
+	SQLHSTMT        StatementHandle,
+	SQLUSMALLINT    ColumnNumber,
+	SQLUSMALLINT    FieldIdentifier,
+	SQLPOINTER      CharacterAttributePtr,
+	SQLSMALLINT     BufferLength,
+	SQLSMALLINT *   StringLengthPtr,
+	void *        NumericAttributePtr);
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Columns returns the columns of the current result set. They are
+// described once per result set, after execution and NextResult, and
+// are empty for statements that return no rows.
+func (stmt *Statement) Columns() ([]Field, *ODBCError) {
+	if stmt.fields != nil {
+		return stmt.fields, nil
+	}
+	n := (*C.SQLSMALLINT)(cmalloc(unsafe.Sizeof(C.SQLSMALLINT(0))))
+	defer C.free(unsafe.Pointer(n))
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLNumResultCols(C.SQLHSTMT(stmt.handle), n)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return nil, err
+	}
+	fields := make([]Field, *n)
+	for i := range fields {
+		if err := stmt.describeColumn(i+1, &fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	stmt.fields = fields
+	return fields, nil
+}
+
+// colDesc receives the output of SQLDescribeCol, in C memory.
+type colDesc struct {
+	nameLength, dataType, decimalDigits, nullable C.SQLSMALLINT
+	columnSize                                    C.SQLULEN
+}
+
+func (stmt *Statement) describeColumn(col int, f *Field) *ODBCError {
+	d := (*colDesc)(cmalloc(unsafe.Sizeof(colDesc{})))
+	defer C.free(unsafe.Pointer(d))
+	name := cmalloc(uintptr(INFO_BUFFER_LEN * wcharSize))
+	defer C.free(name)
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLDescribeColW(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(col), (*C.SQLWCHAR)(name), INFO_BUFFER_LEN,
+			&d.nameLength, &d.dataType, &d.columnSize, &d.decimalDigits, &d.nullable)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	f.Name = wideToString(C.GoBytes(name, C.int(INFO_BUFFER_LEN*wcharSize)))
+	f.Type = int(d.dataType)
+	f.Size = int(d.columnSize)
+	f.DecimalDigits = int(d.decimalDigits)
+	f.Nullable = int(d.nullable)
+
+	length, err := stmt.colAttrInt(col, C.SQL_DESC_LENGTH)
+	if err != nil {
+		return err
+	}
+	f.length = C.SQLLEN(length)
+
+	// Not all drivers report these; leave them empty if so.
+	f.TypeName, _ = stmt.colAttrString(col, C.SQL_DESC_TYPE_NAME)
+	f.Label, _ = stmt.colAttrString(col, C.SQL_DESC_LABEL)
+	f.BaseTable, _ = stmt.colAttrString(col, C.SQL_DESC_BASE_TABLE_NAME)
+	f.BaseColumn, _ = stmt.colAttrString(col, C.SQL_DESC_BASE_COLUMN_NAME)
+	if v, err := stmt.colAttrInt(col, C.SQL_DESC_AUTO_UNIQUE_VALUE); err == nil {
+		f.AutoIncrement = v == C.SQL_TRUE
+	}
+	if v, err := stmt.colAttrInt(col, C.SQL_DESC_UNSIGNED); err == nil {
+		f.Unsigned = v == C.SQL_TRUE
+	}
+	if v, err := stmt.colAttrInt(col, C.SQL_DESC_CASE_SENSITIVE); err == nil {
+		f.CaseSensitive = v == C.SQL_TRUE
+	}
+	return nil
+}
+
+// colAttrInt returns the numeric attribute id of column col.
+func (stmt *Statement) colAttrInt(col int, id C.SQLUSMALLINT) (int, *ODBCError) {
+	value := (*C.SQLLEN)(cmalloc(unsafe.Sizeof(C.SQLLEN(0))))
+	defer C.free(unsafe.Pointer(value))
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C._SQLColAttribute(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(col), id, nil, 0, nil, unsafe.Pointer(value))
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return 0, err
+	}
+	return int(*value), nil
+}
+
+// colAttrString returns the character attribute id of column col.
+func (stmt *Statement) colAttrString(col int, id C.SQLUSMALLINT) (string, *ODBCError) {
+	size := INFO_BUFFER_LEN * wcharSize
+	value := cmalloc(uintptr(size))
+	defer C.free(value)
+	length := (*C.SQLSMALLINT)(cmalloc(unsafe.Sizeof(C.SQLSMALLINT(0))))
+	defer C.free(unsafe.Pointer(length))
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLColAttributeW(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(col), id, C.SQLPOINTER(value), C.SQLSMALLINT(size), length, nil)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return "", err
+	}
+	return wideToString(C.GoBytes(value, C.int(size))), nil
+}
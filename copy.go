@@ -0,0 +1,298 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+const (
+	// COPY_ROWSET_SIZE is the largest number of rows Copy moves per
+	// round trip.
+	COPY_ROWSET_SIZE = 1000
+
+	// COPY_MAX_ROWSET_BYTES caps the buffer memory of Copy, which uses
+	// smaller rowsets for wide rows.
+	COPY_MAX_ROWSET_BYTES = 16 << 20
+
+	// COPY_MAX_VALUE_BYTES is the widest column Copy can move; long data
+	// such as VARCHAR(MAX) columns cannot be bound in rowsets.
+	COPY_MAX_VALUE_BYTES = 64 << 10
+)
+
+// copyColumn is the buffer of a column shared by the source rowset and
+// the destination parameter array.
+type copyColumn struct {
+	cType C.SQLSMALLINT
+	width int // bytes per value
+	limit int // longest value that fits, in bytes, or -1 if fixed-size
+	data  unsafe.Pointer
+	ind   unsafe.Pointer // SQLLEN per row
+}
+
+// Copy moves the rows of the executed query src into the prepared
+// statement dst, typically an INSERT with one parameter marker per
+// column of src. The two may belong to different connections and data
+// sources.
+//
+// The rows are fetched in rowsets into buffers in C memory, which are
+// then bound as the parameter arrays of dst, so values are not converted
+// to Go and back. Character data travels as SQL_C_WCHAR and decimals as
+// text, other types in their native C form. It returns the number of
+// rows inserted. Parameters bound to dst before are reset.
+//
+// Rows that fail to be fetched or inserted are skipped and not counted;
+// Copy goes on with the others and then returns an error describing the
+// first failure. A value longer than its column size stops Copy with an
+// error matching ErrTruncation before its rowset is inserted.
+func Copy(src *Statement, dst *Statement) (int64, *ODBCError) {
+	fields, err := src.Columns()
+	if err != nil {
+		return 0, err
+	}
+	nparams, err := dst.numParams()
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 || nparams != len(fields) {
+		return 0, &ODBCError{SQLState: "07002", ErrorMessage: fmt.Sprintf("Copy: %d columns for %d parameters", len(fields), nparams)}
+	}
+
+	cols := make([]copyColumn, len(fields))
+	rowWidth := 0
+	for i, f := range fields {
+		c, err := copyColumnFor(&f)
+		if err != nil {
+			return 0, err
+		}
+		cols[i] = c
+		rowWidth += c.width + int(unsafe.Sizeof(C.SQLLEN(0)))
+	}
+	rows := COPY_MAX_ROWSET_BYTES / rowWidth
+	if rows > COPY_ROWSET_SIZE {
+		rows = COPY_ROWSET_SIZE
+	} else if rows < 1 {
+		rows = 1
+	}
+	for i := range cols {
+		cols[i].data = C.calloc(C.size_t(rows), C.size_t(cols[i].width))
+		cols[i].ind = C.calloc(C.size_t(rows), C.size_t(unsafe.Sizeof(C.SQLLEN(0))))
+	}
+	fetched := (*C.SQLULEN)(C.calloc(1, C.size_t(unsafe.Sizeof(C.SQLULEN(0)))))
+	processed := (*C.SQLULEN)(C.calloc(1, C.size_t(unsafe.Sizeof(C.SQLULEN(0)))))
+	rowStatus := (*C.SQLUSMALLINT)(C.calloc(C.size_t(rows), C.size_t(unsafe.Sizeof(C.SQLUSMALLINT(0)))))
+	paramOperation := (*C.SQLUSMALLINT)(C.calloc(C.size_t(rows), C.size_t(unsafe.Sizeof(C.SQLUSMALLINT(0)))))
+	paramStatus := (*C.SQLUSMALLINT)(C.calloc(C.size_t(rows), C.size_t(unsafe.Sizeof(C.SQLUSMALLINT(0)))))
+	dst.freeParams()
+	defer func() {
+		C.SQLFreeStmt(C.SQLHSTMT(src.handle), C.SQL_UNBIND)
+		src.setAttr(C.SQL_ATTR_ROWS_FETCHED_PTR, 0)
+		src.setAttr(C.SQL_ATTR_ROW_STATUS_PTR, 0)
+		src.setAttr(C.SQL_ATTR_ROW_ARRAY_SIZE, 1)
+		C.SQLFreeStmt(C.SQLHSTMT(dst.handle), C.SQL_RESET_PARAMS)
+		dst.setAttr(C.SQL_ATTR_PARAMS_PROCESSED_PTR, 0)
+		dst.setAttr(C.SQL_ATTR_PARAM_STATUS_PTR, 0)
+		dst.setAttr(C.SQL_ATTR_PARAM_OPERATION_PTR, 0)
+		dst.setAttr(C.SQL_ATTR_PARAMSET_SIZE, 1)
+		for _, c := range cols {
+			C.free(c.data)
+			C.free(c.ind)
+		}
+		C.free(unsafe.Pointer(fetched))
+		C.free(unsafe.Pointer(processed))
+		C.free(unsafe.Pointer(rowStatus))
+		C.free(unsafe.Pointer(paramOperation))
+		C.free(unsafe.Pointer(paramStatus))
+	}()
+
+	if err := src.setAttr(C.SQL_ATTR_ROW_BIND_TYPE, C.SQL_BIND_BY_COLUMN); err != nil {
+		return 0, err
+	}
+	if err := src.setAttr(C.SQL_ATTR_ROW_ARRAY_SIZE, uintptr(rows)); err != nil {
+		return 0, err
+	}
+	if err := src.setAttr(C.SQL_ATTR_ROWS_FETCHED_PTR, uintptr(unsafe.Pointer(fetched))); err != nil {
+		return 0, err
+	}
+	if err := src.setAttr(C.SQL_ATTR_ROW_STATUS_PTR, uintptr(unsafe.Pointer(rowStatus))); err != nil {
+		return 0, err
+	}
+	if err := dst.setAttr(C.SQL_ATTR_PARAM_BIND_TYPE, C.SQL_PARAM_BIND_BY_COLUMN); err != nil {
+		return 0, err
+	}
+	if err := dst.setAttr(C.SQL_ATTR_PARAMS_PROCESSED_PTR, uintptr(unsafe.Pointer(processed))); err != nil {
+		return 0, err
+	}
+	if err := dst.setAttr(C.SQL_ATTR_PARAM_STATUS_PTR, uintptr(unsafe.Pointer(paramStatus))); err != nil {
+		return 0, err
+	}
+	if err := dst.setAttr(C.SQL_ATTR_PARAM_OPERATION_PTR, uintptr(unsafe.Pointer(paramOperation))); err != nil {
+		return 0, err
+	}
+	for i, c := range cols {
+		f := &fields[i]
+		ret := C.SQLBindCol(C.SQLHSTMT(src.handle), C.SQLUSMALLINT(i+1), c.cType, C.SQLPOINTER(c.data), C.SQLLEN(c.width), (*C.SQLLEN)(c.ind))
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_STMT, src.handle)
+			return 0, err
+		}
+		size := C.SQLULEN(f.Size)
+		if size == 0 {
+			size = 1
+		}
+		ret = C.SQLBindParameter(C.SQLHSTMT(dst.handle), C.SQLUSMALLINT(i+1), C.SQL_PARAM_INPUT, c.cType, C.SQLSMALLINT(f.Type),
+			size, C.SQLSMALLINT(f.DecimalDigits), C.SQLPOINTER(c.data), C.SQLLEN(c.width), (*C.SQLLEN)(c.ind))
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_STMT, dst.handle)
+			return 0, err
+		}
+	}
+
+	rowStatuses := unsafe.Slice(rowStatus, rows)
+	operations := unsafe.Slice(paramOperation, rows)
+	paramStatuses := unsafe.Slice(paramStatus, rows)
+	var total, failed int64
+	var failure *ODBCError
+	for {
+		ret := src.wait(func() C.SQLRETURN {
+			return C.SQLFetch(C.SQLHSTMT(src.handle))
+		})
+		src.checkInfo(ret)
+		if ret == C.SQL_NO_DATA {
+			break
+		}
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_STMT, src.handle)
+			return total, err
+		}
+		n := int(*fetched)
+		for r := 0; r < n; r++ {
+			operations[r] = C.SQL_PARAM_PROCEED
+			if rowStatuses[r] == C.SQL_ROW_ERROR || rowStatuses[r] == C.SQL_ROW_NOROW {
+				operations[r] = C.SQL_PARAM_IGNORE
+				failed++
+				if failure == nil {
+					failure = FormatError(C.SQL_HANDLE_STMT, src.handle)
+				}
+				continue
+			}
+			if err := copyTruncated(cols, fields, r); err != nil {
+				return total, err
+			}
+		}
+		if err := dst.setAttr(C.SQL_ATTR_PARAMSET_SIZE, uintptr(n)); err != nil {
+			return total, err
+		}
+		// Rows the driver does not get to keep this status.
+		for r := 0; r < n; r++ {
+			paramStatuses[r] = C.SQL_PARAM_UNUSED
+		}
+		*processed = 0
+		ret = dst.wait(func() C.SQLRETURN {
+			return C.SQLExecute(C.SQLHSTMT(dst.handle))
+		})
+		dst.checkInfo(ret)
+		if !Success(ret) && ret != C.SQL_NO_DATA && *processed == 0 {
+			err := FormatError(C.SQL_HANDLE_STMT, dst.handle)
+			return total, err
+		}
+		var batchFailed int64
+		for r := 0; r < n; r++ {
+			switch paramStatuses[r] {
+			case C.SQL_PARAM_SUCCESS, C.SQL_PARAM_SUCCESS_WITH_INFO:
+				total++
+			case C.SQL_PARAM_UNUSED:
+				if operations[r] == C.SQL_PARAM_PROCEED {
+					batchFailed++
+				}
+			default:
+				batchFailed++
+			}
+		}
+		if batchFailed > 0 && failure == nil {
+			failure = FormatError(C.SQL_HANDLE_STMT, dst.handle)
+		}
+		failed += batchFailed
+	}
+	if failed > 0 {
+		if failure == nil {
+			failure = &ODBCError{SQLState: "HY000"}
+		}
+		failure.ErrorMessage = fmt.Sprintf("Copy: %d rows not copied: %s", failed, failure.ErrorMessage)
+		return total, failure
+	}
+	return total, nil
+}
+
+// copyTruncated returns an error matching ErrTruncation if a value of
+// row r did not fit in its column buffer.
+func copyTruncated(cols []copyColumn, fields []Field, r int) *ODBCError {
+	for i, c := range cols {
+		if c.limit < 0 {
+			continue
+		}
+		ind := *(*C.SQLLEN)(unsafe.Add(c.ind, r*int(unsafe.Sizeof(C.SQLLEN(0)))))
+		if ind == C.SQL_NO_TOTAL || ind > C.SQLLEN(c.limit) {
+			return &ODBCError{SQLState: "01004", ErrorMessage: fmt.Sprintf("Copy: value of column %s is longer than its size %d", fields[i].Name, fields[i].Size)}
+		}
+	}
+	return nil
+}
+
+// copyColumnFor chooses the C type and buffer width that carry column f
+// unchanged.
+func copyColumnFor(f *Field) (copyColumn, *ODBCError) {
+	c := copyColumn{limit: -1}
+	switch f.Type {
+	case C.SQL_BIT:
+		c.cType, c.width = C.SQL_C_BIT, 1
+	case C.SQL_TINYINT, C.SQL_SMALLINT, C.SQL_INTEGER, C.SQL_BIGINT:
+		c.cType, c.width = C.SQL_C_SBIGINT, 8
+	case C.SQL_REAL, C.SQL_FLOAT, C.SQL_DOUBLE:
+		c.cType, c.width = C.SQL_C_DOUBLE, 8
+	case C.SQL_DECIMAL, C.SQL_NUMERIC:
+		// Sign, decimal point and terminator around the digits.
+		c.cType, c.width = C.SQL_C_CHAR, f.Size+3
+		c.limit = c.width - 1
+	case C.SQL_TYPE_DATE:
+		c.cType, c.width = C.SQL_C_TYPE_DATE, int(unsafe.Sizeof(C.SQL_DATE_STRUCT{}))
+	case C.SQL_TYPE_TIME:
+		c.cType, c.width = C.SQL_C_TYPE_TIME, int(unsafe.Sizeof(C.SQL_TIME_STRUCT{}))
+	case C.SQL_TYPE_TIMESTAMP, C.SQL_DATETIME:
+		c.cType, c.width = C.SQL_C_TYPE_TIMESTAMP, int(unsafe.Sizeof(C.SQL_TIMESTAMP_STRUCT{}))
+	case C.SQL_BINARY, C.SQL_VARBINARY, C.SQL_LONGVARBINARY:
+		c.cType, c.width = C.SQL_C_BINARY, f.Size
+		c.limit = c.width
+	default:
+		// Character data, and anything else as its text form.
+		c.cType, c.width = C.SQL_C_WCHAR, (f.Size+1)*wcharSize
+		c.limit = c.width - wcharSize
+	}
+	if f.Size <= 0 || c.width > COPY_MAX_VALUE_BYTES {
+		switch f.Type {
+		case C.SQL_BIT, C.SQL_TINYINT, C.SQL_SMALLINT, C.SQL_INTEGER, C.SQL_BIGINT,
+			C.SQL_REAL, C.SQL_FLOAT, C.SQL_DOUBLE,
+			C.SQL_TYPE_DATE, C.SQL_TYPE_TIME, C.SQL_TYPE_TIMESTAMP, C.SQL_DATETIME:
+		default:
+			return c, &ODBCError{SQLState: "HYC00", ErrorMessage: fmt.Sprintf("Copy: column %s of size %d is too long to copy in rowsets", f.Name, f.Size)}
+		}
+	}
+	return c, nil
+}
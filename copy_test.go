@@ -0,0 +1,108 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"errors"
+	"testing"
+)
+
+func testCount(t *testing.T, conn *Connection, table string) int64 {
+	t.Helper()
+	stmt, err := conn.ExecDirect("select count(*) from " + table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	row, err := stmt.FetchOne()
+	if err != nil || row == nil {
+		t.Fatalf("FetchOne = %v, %v", row, err)
+	}
+	n, err := row.Int(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func testCopy(t *testing.T, conn *Connection, query, insert string) (int64, *ODBCError) {
+	t.Helper()
+	src, err := conn.ExecDirect(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := conn.Prepare(insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	return Copy(src, dst)
+}
+
+func TestCopy(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_copy_src", "id integer, name varchar(10)")
+	testTable(t, conn, "odbc_copy_dst", "id integer, name varchar(10)")
+	for i, name := range []string{"one", "two", "three"} {
+		testExec(t, conn, "insert into odbc_copy_src values (?, ?)", i+1, name)
+	}
+	testExec(t, conn, "insert into odbc_copy_src values (?, ?)", 4, nil)
+
+	n, err := testCopy(t, conn, "select id, name from odbc_copy_src", "insert into odbc_copy_dst values (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("Copy = %d, want 4", n)
+	}
+	if got := testCount(t, conn, "odbc_copy_dst where name is null"); got != 1 {
+		t.Errorf("%d NULL names copied, want 1", got)
+	}
+}
+
+// TestCopyFailedRow copies a rowset in which one row violates a primary
+// key: only the rows inserted are counted and the failure is reported.
+func TestCopyFailedRow(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_copy_src", "id integer, name varchar(10)")
+	testTable(t, conn, "odbc_copy_dst", "id integer primary key, name varchar(10)")
+	for i, name := range []string{"one", "two", "three"} {
+		testExec(t, conn, "insert into odbc_copy_src values (?, ?)", i+1, name)
+	}
+	testExec(t, conn, "insert into odbc_copy_dst values (?, ?)", 2, "old")
+
+	n, err := testCopy(t, conn, "select id, name from odbc_copy_src order by id", "insert into odbc_copy_dst values (?, ?)")
+	if err == nil {
+		t.Fatal("no error for a duplicate key")
+	}
+	if !errors.Is(err, ErrConstraintViolation) {
+		t.Errorf("error %v does not match ErrConstraintViolation", err)
+	}
+	// Drivers may stop at the failing row or go on with the next.
+	if inserted := testCount(t, conn, "odbc_copy_dst") - 1; n != inserted || n < 1 || n > 2 {
+		t.Errorf("Copy = %d with %d rows inserted", n, inserted)
+	}
+}
+
+func TestCopyTruncation(t *testing.T) {
+	conn := testConn(t)
+	// SQLite stores values longer than the declared size.
+	testTable(t, conn, "odbc_copy_src", "id integer, name varchar(2)")
+	testTable(t, conn, "odbc_copy_dst", "id integer, name varchar(10)")
+	testExec(t, conn, "insert into odbc_copy_src values (?, ?)", 1, "ab")
+	testExec(t, conn, "insert into odbc_copy_src values (?, ?)", 2, "abcdef")
+
+	n, err := testCopy(t, conn, "select id, name from odbc_copy_src", "insert into odbc_copy_dst values (?, ?)")
+	if !errors.Is(err, ErrTruncation) {
+		t.Fatalf("Copy error %v does not match ErrTruncation", err)
+	}
+	if n != 0 {
+		t.Errorf("Copy = %d, want 0", n)
+	}
+	if got := testCount(t, conn, "odbc_copy_dst"); got != 0 {
+		t.Errorf("%d rows inserted, want 0", got)
+	}
+}
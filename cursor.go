@@ -0,0 +1,244 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Cursor concurrency, see SetConcurrency.
+const (
+	CONCUR_READ_ONLY = C.SQL_CONCUR_READ_ONLY
+	CONCUR_LOCK      = C.SQL_CONCUR_LOCK
+	CONCUR_ROWVER    = C.SQL_CONCUR_ROWVER
+	CONCUR_VALUES    = C.SQL_CONCUR_VALUES
+)
+
+// Cursor types, see SetCursorType.
+const (
+	CURSOR_FORWARD_ONLY  = C.SQL_CURSOR_FORWARD_ONLY
+	CURSOR_KEYSET_DRIVEN = C.SQL_CURSOR_KEYSET_DRIVEN
+	CURSOR_DYNAMIC       = C.SQL_CURSOR_DYNAMIC
+	CURSOR_STATIC        = C.SQL_CURSOR_STATIC
+)
+
+func (stmt *Statement) setAttr(attr C.SQLINTEGER, value uintptr) *ODBCError {
+	ret := C.SQLSetStmtAttr(C.SQLHSTMT(stmt.handle), attr, C.SQLPOINTER(unsafe.Pointer(value)), C.SQL_IS_UINTEGER)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	return nil
+}
+
+func (stmt *Statement) getAttr(attr C.SQLINTEGER) (uintptr, *ODBCError) {
+	var value C.SQLULEN
+	ret := C.SQLGetStmtAttr(C.SQLHSTMT(stmt.handle), attr, C.SQLPOINTER(unsafe.Pointer(&value)), C.SQL_IS_UINTEGER, nil)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return 0, err
+	}
+	return uintptr(value), nil
+}
+
+// SetCursorName names the cursor of the statement, for use in
+// "UPDATE ... WHERE CURRENT OF name" from another statement.
+func (stmt *Statement) SetCursorName(name string) *ODBCError {
+	ret := C.SQLSetCursorNameW(C.SQLHSTMT(stmt.handle), wstr(stringToWide(name)), C.SQL_NTS)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	return nil
+}
+
+// CursorName returns the cursor name set by SetCursorName, or the name
+// generated by the driver.
+func (stmt *Statement) CursorName() (string, *ODBCError) {
+	var nameLen C.SQLSMALLINT
+	name := wideBuffer(INFO_BUFFER_LEN)
+	ret := C.SQLGetCursorNameW(C.SQLHSTMT(stmt.handle), wstr(name), INFO_BUFFER_LEN, &nameLen)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return "", err
+	}
+	return wideToString(name), nil
+}
+
+// SetConcurrency sets the cursor concurrency to one of the CONCUR_*
+// constants. It must be called before the statement is prepared or
+// executed, see Connection.NewStatement.
+func (stmt *Statement) SetConcurrency(concurrency int) *ODBCError {
+	return stmt.setAttr(C.SQL_ATTR_CONCURRENCY, uintptr(concurrency))
+}
+
+func (stmt *Statement) Concurrency() (int, *ODBCError) {
+	v, err := stmt.getAttr(C.SQL_ATTR_CONCURRENCY)
+	return int(v), err
+}
+
+// SetCursorType sets the cursor type to one of the CURSOR_* constants.
+// Updatable cursors usually need CURSOR_KEYSET_DRIVEN or CURSOR_DYNAMIC.
+func (stmt *Statement) SetCursorType(cursorType int) *ODBCError {
+	if err := stmt.setAttr(C.SQL_ATTR_CURSOR_TYPE, uintptr(cursorType)); err != nil {
+		return err
+	}
+	stmt.scrollable = cursorType != CURSOR_FORWARD_ONLY
+	return nil
+}
+
+func (stmt *Statement) CursorType() (int, *ODBCError) {
+	v, err := stmt.getAttr(C.SQL_ATTR_CURSOR_TYPE)
+	return int(v), err
+}
+
+func (stmt *Statement) setPos(operation C.SQLUSMALLINT) *ODBCError {
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLSetPos(C.SQLHSTMT(stmt.handle), 1, operation, C.SQL_LOCK_NO_CHANGE)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	return nil
+}
+
+// colBinding is a column binding of the ARD, saved by bindColumns to be
+// restored afterwards.
+type colBinding struct {
+	col    int
+	cType  int
+	data   unsafe.Pointer
+	length int
+	octets unsafe.Pointer // SQL_DESC_OCTET_LENGTH_PTR
+	ind    unsafe.Pointer // SQL_DESC_INDICATOR_PTR
+}
+
+// binding returns the binding of column col in ard; data is nil if the
+// column is not bound.
+func (ard *Descriptor) binding(col int) (b colBinding, err *ODBCError) {
+	b.col = col
+	if col > 0 {
+		n, err := ard.Field(0, DESC_COUNT)
+		if err != nil || col > n {
+			return b, err
+		}
+	}
+	if b.data, err = ard.FieldPointer(col, DESC_DATA_PTR); err != nil || b.data == nil {
+		return b, err
+	}
+	if b.cType, err = ard.Field(col, DESC_CONCISE_TYPE); err != nil {
+		return b, err
+	}
+	if b.length, err = ard.Field(col, DESC_OCTET_LENGTH); err != nil {
+		return b, err
+	}
+	if b.octets, err = ard.FieldPointer(col, DESC_OCTET_LENGTH_PTR); err != nil {
+		return b, err
+	}
+	b.ind, err = ard.FieldPointer(col, DESC_INDICATOR_PTR)
+	return b, err
+}
+
+// restore binds the column again as saved, or unbinds it if it was not
+// bound.
+func (stmt *Statement) restore(ard *Descriptor, b colBinding) {
+	if b.data == nil {
+		C.SQLBindCol(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(b.col), C.SQL_C_DEFAULT, nil, 0, nil)
+		return
+	}
+	C.SQLBindCol(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(b.col), C.SQLSMALLINT(b.cType), C.SQLPOINTER(b.data), C.SQLLEN(b.length), (*C.SQLLEN)(b.ind))
+	if b.octets != b.ind {
+		ard.SetFieldPointer(b.col, DESC_OCTET_LENGTH_PTR, b.octets)
+	}
+}
+
+// bindColumns binds values, keyed by column index as for GetField, to
+// the current rowset using buffers in C memory. A non-nil bookmark is
+// bound to column 0. The returned release function restores the
+// bindings these columns had before, leaving the other columns alone,
+// and frees the buffers.
+func (stmt *Statement) bindColumns(bookmark []byte, values map[int]interface{}) (release func(), err *ODBCError) {
+	ard, err := stmt.ARD()
+	if err != nil {
+		return nil, err
+	}
+	var saved []colBinding
+	var bound []*cValue
+	release = func() {
+		for i := len(saved) - 1; i >= 0; i-- {
+			stmt.restore(ard, saved[i])
+		}
+		for _, cv := range bound {
+			cv.free()
+		}
+	}
+	bind := func(col int, value interface{}) *ODBCError {
+		b, err := ard.binding(col)
+		if err != nil {
+			return err
+		}
+		cv, err := newCValue(value, stmt.enc, stmt.wideStrings())
+		if err != nil {
+			return err
+		}
+		bound = append(bound, cv)
+		saved = append(saved, b)
+		ret := C.SQLBindCol(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(col), cv.cType, C.SQLPOINTER(cv.buf), cv.buflen, cv.ind)
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+			return err
+		}
+		return nil
+	}
+	if bookmark != nil {
+		if err = bind(0, bookmark); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	for i, value := range values {
+		if err = bind(i+1, value); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// UpdateRow updates the current row of the cursor in place. values maps
+// column index (as for GetField) to the new value; columns not present
+// are left unchanged.
+func (stmt *Statement) UpdateRow(values map[int]interface{}) *ODBCError {
+	release, err := stmt.bindColumns(nil, values)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return stmt.setPos(C.SQL_UPDATE)
+}
+
+// DeleteRow deletes the current row of the cursor.
+func (stmt *Statement) DeleteRow() *ODBCError {
+	return stmt.setPos(C.SQL_DELETE)
+}
+
+// RefreshRow re-reads the current row of the cursor from the data
+// source, so that GetField returns its latest values.
+func (stmt *Statement) RefreshRow() *ODBCError {
+	return stmt.setPos(C.SQL_REFRESH)
+}
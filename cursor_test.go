@@ -0,0 +1,128 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"testing"
+)
+
+// testUnsupported skips t if err reports a feature the driver lacks.
+func testUnsupported(t *testing.T, what string, err *ODBCError) {
+	t.Helper()
+	if err != nil && (err.SQLState == "HYC00" || err.SQLState == "IM001") {
+		t.Skip(what, ": ", err)
+	}
+}
+
+func testCursorTable(t *testing.T) *Connection {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_cursor", "id integer primary key, name varchar(20)")
+	for i, name := range []string{"ann", "bob", "cid"} {
+		testExec(t, conn, "insert into odbc_cursor values (?, ?)", i+1, name)
+	}
+	return conn
+}
+
+// TestBindColumnsRestore checks that the bindings made for one call are
+// undone without touching the other columns.
+func TestBindColumnsRestore(t *testing.T) {
+	conn := testCursorTable(t)
+	stmt, err := conn.ExecDirect("select id, name from odbc_cursor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	ard, err := stmt.ARD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, err := stmt.bindColumns(nil, map[int]interface{}{0: int64(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outer()
+	before, err := ard.binding(1)
+	if err != nil || before.data == nil {
+		t.Fatalf("column 1 not bound: %+v, %v", before, err)
+	}
+	inner, err := stmt.bindColumns(nil, map[int]interface{}{0: int64(1), 1: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ard.binding(1); b.data == before.data {
+		t.Error("column 1 not bound again")
+	}
+	inner()
+	if b, err := ard.binding(1); err != nil || b != before {
+		t.Errorf("column 1 bound to %+v, %v after release, want %+v", b, err, before)
+	}
+	if b, err := ard.binding(2); err != nil || b.data != nil {
+		t.Errorf("column 2 still bound to %+v, %v", b, err)
+	}
+}
+
+func TestPositionedUpdate(t *testing.T) {
+	conn := testCursorTable(t)
+	stmt, err := conn.NewStatement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	err = stmt.SetCursorType(CURSOR_KEYSET_DRIVEN)
+	testUnsupported(t, "keyset cursor", err)
+	if err != nil && err.SQLState != "01S02" {
+		t.Fatal(err)
+	}
+	err = stmt.SetConcurrency(CONCUR_LOCK)
+	testUnsupported(t, "locking concurrency", err)
+	if err != nil && err.SQLState != "01S02" {
+		t.Fatal(err)
+	}
+	if c, err := stmt.Concurrency(); err != nil || c == CONCUR_READ_ONLY {
+		t.Skipf("concurrency %d, %v: cursor is read-only", c, err)
+	}
+	if _, err := stmt.CursorType(); err != nil {
+		t.Error(err)
+	}
+	if err := stmt.SetCursorName("odbc_cur"); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := stmt.CursorName(); err != nil || name != "odbc_cur" {
+		t.Errorf("cursor name %q, %v", name, err)
+	}
+	if err := stmt.ExecDirect("select id, name from odbc_cursor order by id"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := stmt.Fetch(); !ok || err != nil {
+		t.Fatalf("Fetch = %v, %v", ok, err)
+	}
+	err = stmt.UpdateRow(map[int]interface{}{1: "ann2"})
+	testUnsupported(t, "positioned update", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stmt.RefreshRow(); err != nil {
+		t.Error("RefreshRow: ", err)
+	} else if v, _, _, err := stmt.GetField(1); err != nil || v != "ann2" {
+		t.Errorf("refreshed row has %v, %v", v, err)
+	}
+	if ok, err := stmt.Fetch(); !ok || err != nil {
+		t.Fatalf("Fetch = %v, %v", ok, err)
+	}
+	if err := stmt.DeleteRow(); err != nil {
+		t.Fatal("DeleteRow: ", err)
+	}
+	stmt.Rows().Close()
+
+	if n := testCount(t, conn, "odbc_cursor where id = 1 and name = 'ann2'"); n != 1 {
+		t.Error("row 1 not updated")
+	}
+	if n := testCount(t, conn, "odbc_cursor where id = 2"); n != 0 {
+		t.Error("row 2 not deleted")
+	}
+	if n := testCount(t, conn, "odbc_cursor"); n != 2 {
+		t.Errorf("%d rows left, want 2", n)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+
+// SQLLEN and SQLULEN fields have no SQL_IS_* constant, pass their size.
+#define SQL_IS_LEN ((SQLINTEGER)sizeof(SQLLEN))
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Descriptor header and record fields, see Descriptor.Field.
+const (
+	DESC_ALLOC_TYPE         = C.SQL_DESC_ALLOC_TYPE
+	DESC_ARRAY_SIZE         = C.SQL_DESC_ARRAY_SIZE
+	DESC_ARRAY_STATUS_PTR   = C.SQL_DESC_ARRAY_STATUS_PTR
+	DESC_BIND_OFFSET_PTR    = C.SQL_DESC_BIND_OFFSET_PTR
+	DESC_BIND_TYPE          = C.SQL_DESC_BIND_TYPE
+	DESC_COUNT              = C.SQL_DESC_COUNT
+	DESC_ROWS_PROCESSED_PTR = C.SQL_DESC_ROWS_PROCESSED_PTR
+
+	DESC_CONCISE_TYPE           = C.SQL_DESC_CONCISE_TYPE
+	DESC_DATA_PTR               = C.SQL_DESC_DATA_PTR
+	DESC_DATETIME_INTERVAL_CODE = C.SQL_DESC_DATETIME_INTERVAL_CODE
+	DESC_INDICATOR_PTR          = C.SQL_DESC_INDICATOR_PTR
+	DESC_LENGTH                 = C.SQL_DESC_LENGTH
+	DESC_NAME                   = C.SQL_DESC_NAME
+	DESC_NULLABLE               = C.SQL_DESC_NULLABLE
+	DESC_OCTET_LENGTH           = C.SQL_DESC_OCTET_LENGTH
+	DESC_OCTET_LENGTH_PTR       = C.SQL_DESC_OCTET_LENGTH_PTR
+	DESC_PARAMETER_TYPE         = C.SQL_DESC_PARAMETER_TYPE
+	DESC_PRECISION              = C.SQL_DESC_PRECISION
+	DESC_SCALE                  = C.SQL_DESC_SCALE
+	DESC_TYPE                   = C.SQL_DESC_TYPE
+	DESC_TYPE_NAME              = C.SQL_DESC_TYPE_NAME
+	DESC_UNNAMED                = C.SQL_DESC_UNNAMED
+)
+
+// Descriptor is an ODBC descriptor: one of the four implicit descriptors
+// of a statement (ARD, APD, IRD, IPD) or an explicit descriptor from
+// Connection.AllocDescriptor.
+//
+// Pointer fields such as DESC_DATA_PTR, DESC_INDICATOR_PTR and
+// DESC_BIND_OFFSET_PTR are kept by the driver past the call, so they
+// must point to C memory that stays valid while the descriptor is used.
+type Descriptor struct {
+	handle   C.SQLHANDLE
+	explicit bool
+}
+
+// DescRec is a descriptor record as set by Descriptor.SetRec.
+type DescRec struct {
+	Type      int // SQL_DESC_TYPE, a verbose SQL or C type
+	Subtype   int // SQL_DESC_DATETIME_INTERVAL_CODE
+	Length    int // SQL_DESC_OCTET_LENGTH
+	Precision int
+	Scale     int
+
+	Data         unsafe.Pointer // SQL_DESC_DATA_PTR
+	StringLength unsafe.Pointer // SQL_DESC_OCTET_LENGTH_PTR, an SQLLEN
+	Indicator    unsafe.Pointer // SQL_DESC_INDICATOR_PTR, an SQLLEN
+}
+
+func (stmt *Statement) descriptor(attr C.SQLINTEGER) (*Descriptor, *ODBCError) {
+	var h C.SQLHANDLE
+	ret := C.SQLGetStmtAttr(C.SQLHSTMT(stmt.handle), attr, C.SQLPOINTER(unsafe.Pointer(&h)), C.SQL_IS_POINTER, nil)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return nil, err
+	}
+	return &Descriptor{handle: h}, nil
+}
+
+// ARD returns the application row descriptor of the statement, which
+// describes the buffers bound with SQLBindCol.
+func (stmt *Statement) ARD() (*Descriptor, *ODBCError) {
+	return stmt.descriptor(C.SQL_ATTR_APP_ROW_DESC)
+}
+
+// APD returns the application parameter descriptor of the statement,
+// which describes the parameter buffers.
+func (stmt *Statement) APD() (*Descriptor, *ODBCError) {
+	return stmt.descriptor(C.SQL_ATTR_APP_PARAM_DESC)
+}
+
+// IRD returns the implementation row descriptor of the statement, which
+// describes the columns of the result set.
+func (stmt *Statement) IRD() (*Descriptor, *ODBCError) {
+	return stmt.descriptor(C.SQL_ATTR_IMP_ROW_DESC)
+}
+
+// IPD returns the implementation parameter descriptor of the statement,
+// which describes the parameters as the data source sees them.
+func (stmt *Statement) IPD() (*Descriptor, *ODBCError) {
+	return stmt.descriptor(C.SQL_ATTR_IMP_PARAM_DESC)
+}
+
+// SetARD makes the explicit descriptor d the application row descriptor
+// of the statement. A nil d restores the implicit one.
+func (stmt *Statement) SetARD(d *Descriptor) *ODBCError {
+	return stmt.setDescriptor(C.SQL_ATTR_APP_ROW_DESC, d)
+}
+
+// SetAPD makes the explicit descriptor d the application parameter
+// descriptor of the statement. A nil d restores the implicit one.
+func (stmt *Statement) SetAPD(d *Descriptor) *ODBCError {
+	return stmt.setDescriptor(C.SQL_ATTR_APP_PARAM_DESC, d)
+}
+
+func (stmt *Statement) setDescriptor(attr C.SQLINTEGER, d *Descriptor) *ODBCError {
+	var h C.SQLHANDLE
+	if d != nil {
+		h = d.handle
+	}
+	ret := C.SQLSetStmtAttr(C.SQLHSTMT(stmt.handle), attr, C.SQLPOINTER(h), C.SQL_IS_POINTER)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	return nil
+}
+
+// AllocDescriptor allocates an explicit descriptor, to be shared as the
+// ARD or APD of statements of the connection. Free it when done.
+func (conn *Connection) AllocDescriptor() (*Descriptor, *ODBCError) {
+	var h C.SQLHANDLE
+	ret := C.SQLAllocHandle(C.SQL_HANDLE_DESC, conn.Dbc, &h)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return nil, err
+	}
+	return &Descriptor{handle: h, explicit: true}, nil
+}
+
+// Free frees an explicit descriptor. Statements using it revert to their
+// implicit descriptors. Implicit descriptors are freed with their
+// statement, and Free does nothing for them.
+func (d *Descriptor) Free() *ODBCError {
+	if !d.explicit || d.handle == nil {
+		return nil
+	}
+	ret := C.SQLFreeHandle(C.SQL_HANDLE_DESC, d.handle)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return err
+	}
+	d.handle = nil
+	return nil
+}
+
+// descFieldKind returns the SQL_IS_* kind of the integer or pointer
+// field id.
+func descFieldKind(id int) C.SQLINTEGER {
+	switch id {
+	case C.SQL_DESC_ALLOC_TYPE, C.SQL_DESC_COUNT, C.SQL_DESC_CONCISE_TYPE,
+		C.SQL_DESC_DATETIME_INTERVAL_CODE, C.SQL_DESC_FIXED_PREC_SCALE,
+		C.SQL_DESC_NULLABLE, C.SQL_DESC_PARAMETER_TYPE, C.SQL_DESC_PRECISION,
+		C.SQL_DESC_ROWVER, C.SQL_DESC_SCALE, C.SQL_DESC_SEARCHABLE,
+		C.SQL_DESC_TYPE, C.SQL_DESC_UNNAMED, C.SQL_DESC_UNSIGNED,
+		C.SQL_DESC_UPDATABLE:
+		return C.SQL_IS_SMALLINT
+	case C.SQL_DESC_AUTO_UNIQUE_VALUE, C.SQL_DESC_BIND_TYPE, C.SQL_DESC_CASE_SENSITIVE,
+		C.SQL_DESC_DATETIME_INTERVAL_PRECISION, C.SQL_DESC_NUM_PREC_RADIX:
+		return C.SQL_IS_INTEGER
+	case C.SQL_DESC_ARRAY_STATUS_PTR, C.SQL_DESC_BIND_OFFSET_PTR, C.SQL_DESC_ROWS_PROCESSED_PTR,
+		C.SQL_DESC_DATA_PTR, C.SQL_DESC_INDICATOR_PTR, C.SQL_DESC_OCTET_LENGTH_PTR:
+		return C.SQL_IS_POINTER
+	}
+	return C.SQL_IS_LEN // SQLLEN and SQLULEN fields
+}
+
+// Field returns the integer field id (DESC_*) of record rec, or of the
+// header if rec is 0.
+func (d *Descriptor) Field(rec int, id int) (int, *ODBCError) {
+	// Large enough and aligned for every kind.
+	p := cmalloc(unsafe.Sizeof(C.SQLLEN(0)) + unsafe.Sizeof(C.SQLPOINTER(nil)))
+	defer C.free(p)
+	kind := descFieldKind(id)
+	ret := C.SQLGetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(p), kind, nil)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return 0, err
+	}
+	switch kind {
+	case C.SQL_IS_SMALLINT:
+		return int(*(*C.SQLSMALLINT)(p)), nil
+	case C.SQL_IS_INTEGER:
+		return int(*(*C.SQLINTEGER)(p)), nil
+	case C.SQL_IS_POINTER:
+		return int(uintptr(*(*C.SQLPOINTER)(p))), nil
+	}
+	return int(*(*C.SQLLEN)(p)), nil
+}
+
+// FieldString returns the character field id, such as DESC_NAME, of
+// record rec.
+func (d *Descriptor) FieldString(rec int, id int) (string, *ODBCError) {
+	value := wideBuffer(INFO_BUFFER_LEN)
+	var length C.SQLINTEGER
+	ret := C.SQLGetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(unsafe.Pointer(&value[0])), C.SQLINTEGER(len(value)), &length)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return "", err
+	}
+	return wideToString(value), nil
+}
+
+// FieldPointer returns the pointer field id, such as DESC_DATA_PTR, of
+// record rec.
+func (d *Descriptor) FieldPointer(rec int, id int) (unsafe.Pointer, *ODBCError) {
+	var p C.SQLPOINTER
+	ret := C.SQLGetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(unsafe.Pointer(&p)), C.SQL_IS_POINTER, nil)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return nil, err
+	}
+	return unsafe.Pointer(p), nil
+}
+
+// SetField sets the integer field id of record rec, or of the header if
+// rec is 0.
+func (d *Descriptor) SetField(rec int, id int, value int) *ODBCError {
+	kind := descFieldKind(id)
+	if kind == C.SQL_IS_LEN {
+		// The value travels in the pointer argument itself.
+		kind = C.SQL_IS_INTEGER
+	}
+	ret := C.SQLSetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(unsafe.Pointer(uintptr(value))), kind)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return err
+	}
+	return nil
+}
+
+// SetFieldString sets the character field id of record rec.
+func (d *Descriptor) SetFieldString(rec int, id int, value string) *ODBCError {
+	ret := C.SQLSetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(unsafe.Pointer(wstr(stringToWide(value)))), C.SQL_NTS)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return err
+	}
+	return nil
+}
+
+// SetFieldPointer sets the pointer field id of record rec, or of the
+// header if rec is 0. p must point to C memory.
+func (d *Descriptor) SetFieldPointer(rec int, id int, p unsafe.Pointer) *ODBCError {
+	ret := C.SQLSetDescFieldW(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(id), C.SQLPOINTER(p), C.SQL_IS_POINTER)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return err
+	}
+	return nil
+}
+
+// SetRec sets the fields of record rec that describe a column or
+// parameter and its buffers in one call.
+func (d *Descriptor) SetRec(rec int, r DescRec) *ODBCError {
+	ret := C.SQLSetDescRec(C.SQLHDESC(d.handle), C.SQLSMALLINT(rec), C.SQLSMALLINT(r.Type), C.SQLSMALLINT(r.Subtype),
+		C.SQLLEN(r.Length), C.SQLSMALLINT(r.Precision), C.SQLSMALLINT(r.Scale),
+		C.SQLPOINTER(r.Data), (*C.SQLLEN)(r.StringLength), (*C.SQLLEN)(r.Indicator))
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, d.handle)
+		return err
+	}
+	return nil
+}
+
+// CopyTo copies the fields of d to dst, which cannot be an IRD.
+func (d *Descriptor) CopyTo(dst *Descriptor) *ODBCError {
+	ret := C.SQLCopyDesc(C.SQLHDESC(d.handle), C.SQLHDESC(dst.handle))
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DESC, dst.handle)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// TestDescriptorFields reads small, SQLLEN and pointer fields, which are
+// written by the driver in different sizes.
+func TestDescriptorFields(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_desc", "id integer, name varchar(20)")
+	stmt, err := conn.ExecDirect("select id, name from odbc_desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	ird, err := stmt.IRD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ird.Field(0, DESC_COUNT); err != nil || n != 2 {
+		t.Errorf("IRD count = %d, %v, want 2", n, err)
+	}
+	if n, err := ird.Field(2, DESC_OCTET_LENGTH); err != nil || n < 20 || n > 1<<20 {
+		t.Errorf("varchar(20) octet length = %d, %v", n, err)
+	}
+	if name, err := ird.FieldString(2, DESC_NAME); err != nil || name != "name" {
+		t.Errorf("column name = %q, %v", name, err)
+	}
+
+	ard, err := conn.AllocDescriptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ard.Free()
+	const sqlCChar = 1
+	if err := ard.SetField(1, DESC_TYPE, sqlCChar); err != nil {
+		t.Fatal(err)
+	}
+	// Beyond 32 bits where SQLLEN is 64 bits wide.
+	length := 1<<20 + 3
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		length = 1<<33 + 3
+	}
+	if err := ard.SetField(1, DESC_OCTET_LENGTH, length); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ard.Field(1, DESC_OCTET_LENGTH); err != nil || n != length {
+		t.Errorf("octet length = %d, %v, want %d", n, err, length)
+	}
+	if n, err := ard.Field(1, DESC_TYPE); err != nil || n != sqlCChar {
+		t.Errorf("type = %d, %v, want %d", n, err, sqlCChar)
+	}
+	cv, err := newCValue(int64(0), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cv.free()
+	buf := cv.buf
+	if err := ard.SetFieldPointer(1, DESC_DATA_PTR, buf); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := ard.FieldPointer(1, DESC_DATA_PTR); err != nil || p != buf {
+		t.Errorf("data pointer = %p, %v, want %p", p, err, buf)
+	}
+	if n, err := ard.Field(1, DESC_DATA_PTR); err != nil || uintptr(n) != uintptr(buf) {
+		t.Errorf("data pointer as integer = %#x, %v, want %p", n, err, buf)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Special values of DiagRecord.RowNumber and DiagRecord.ColumnNumber.
+const (
+	NO_ROW_NUMBER         = C.SQL_NO_ROW_NUMBER
+	ROW_NUMBER_UNKNOWN    = C.SQL_ROW_NUMBER_UNKNOWN
+	NO_COLUMN_NUMBER      = C.SQL_NO_COLUMN_NUMBER
+	COLUMN_NUMBER_UNKNOWN = C.SQL_COLUMN_NUMBER_UNKNOWN
+)
+
+// DiagRecord is one diagnostic record of an ODBC handle.
+type DiagRecord struct {
+	SQLState    string
+	NativeError int
+	Message     string
+
+	// RowNumber and ColumnNumber locate the failing row of a parameter
+	// array or rowset and the failing column, both counted from 1. They
+	// are only set for statement handles; otherwise, or when the driver
+	// cannot tell, they hold one of the NO_* or *_UNKNOWN constants.
+	RowNumber    int
+	ColumnNumber int
+
+	ClassOrigin    string
+	SubclassOrigin string
+	ServerName     string
+	ConnectionName string
+}
+
+func newDiagRecord(ht C.SQLSMALLINT, h C.SQLHANDLE, i int) DiagRecord {
+	rec := DiagRecord{
+		RowNumber:    NO_ROW_NUMBER,
+		ColumnNumber: NO_COLUMN_NUMBER,
+	}
+	if ht == C.SQL_HANDLE_STMT {
+		if n, ok := diagFieldInt(ht, h, i, C.SQL_DIAG_ROW_NUMBER); ok {
+			rec.RowNumber = int(n)
+		}
+		if n, ok := diagFieldInteger(ht, h, i, C.SQL_DIAG_COLUMN_NUMBER); ok {
+			rec.ColumnNumber = int(n)
+		}
+	}
+	rec.ClassOrigin = diagFieldString(ht, h, i, C.SQL_DIAG_CLASS_ORIGIN)
+	rec.SubclassOrigin = diagFieldString(ht, h, i, C.SQL_DIAG_SUBCLASS_ORIGIN)
+	rec.ServerName = diagFieldString(ht, h, i, C.SQL_DIAG_SERVER_NAME)
+	rec.ConnectionName = diagFieldString(ht, h, i, C.SQL_DIAG_CONNECTION_NAME)
+	return rec
+}
+
+// diagFieldInt returns a SQLLEN diagnostic field of record i, or of the
+// header when i is 0.
+func diagFieldInt(ht C.SQLSMALLINT, h C.SQLHANDLE, i int, field C.SQLSMALLINT) (C.SQLLEN, bool) {
+	var value C.SQLLEN
+	ret := C.SQLGetDiagFieldW(ht, h, C.SQLSMALLINT(i), field, C.SQLPOINTER(unsafe.Pointer(&value)), 0, nil)
+	return value, Success(ret)
+}
+
+// diagFieldInteger is like diagFieldInt for SQLINTEGER fields.
+func diagFieldInteger(ht C.SQLSMALLINT, h C.SQLHANDLE, i int, field C.SQLSMALLINT) (C.SQLINTEGER, bool) {
+	var value C.SQLINTEGER
+	ret := C.SQLGetDiagFieldW(ht, h, C.SQLSMALLINT(i), field, C.SQLPOINTER(unsafe.Pointer(&value)), 0, nil)
+	return value, Success(ret)
+}
+
+// diagFieldString returns a character diagnostic field of record i, or
+// of the header when i is 0. Unavailable fields read as "".
+func diagFieldString(ht C.SQLSMALLINT, h C.SQLHANDLE, i int, field C.SQLSMALLINT) string {
+	var length C.SQLSMALLINT
+	value := wideBuffer(INFO_BUFFER_LEN)
+	ret := C.SQLGetDiagFieldW(ht, h, C.SQLSMALLINT(i), field, C.SQLPOINTER(unsafe.Pointer(&value[0])), C.SQLSMALLINT(len(value)), &length)
+	if !Success(ret) {
+		return ""
+	}
+	return wideToString(value)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build odbc_dlopen && !windows
+
+// The ODBC API resolved at run time from the driver manager library
+// loaded by odbc_load, for builds with the odbc_dlopen tag. Every
+// function forwards to the library, or fails with SQL_ERROR while none
+// is loaded. sql.h is deliberately not included: only the calling
+// convention has to match, not the driver manager's exact typedefs.
+
+#include <dlfcn.h>
+#include <stdio.h>
+#include <string.h>
+
+typedef short SQLRETURN;
+typedef short SQLSMALLINT;
+typedef unsigned short SQLUSMALLINT;
+typedef int SQLINTEGER;
+typedef long SQLLEN;
+typedef unsigned long SQLULEN;
+typedef void *SQLPOINTER;
+typedef void *SQLHANDLE;
+typedef unsigned char SQLCHAR;
+typedef void SQLWCHAR;
+
+#define ODBC_SQL_ERROR (-1)
+
+#define ODBC_FUNCS(X) \
+	X(SQLAllocHandle, (SQLSMALLINT a, SQLHANDLE b, SQLHANDLE *c), (a, b, c)) \
+	X(SQLFreeHandle, (SQLSMALLINT a, SQLHANDLE b), (a, b)) \
+	X(SQLFreeStmt, (SQLHANDLE a, SQLUSMALLINT b), (a, b)) \
+	X(SQLSetEnvAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d), (a, b, c, d)) \
+	X(SQLGetEnvAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d, SQLINTEGER *e), (a, b, c, d, e)) \
+	X(SQLSetConnectAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d), (a, b, c, d)) \
+	X(SQLSetConnectAttrW, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d), (a, b, c, d)) \
+	X(SQLGetConnectAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d, SQLINTEGER *e), (a, b, c, d, e)) \
+	X(SQLSetStmtAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d), (a, b, c, d)) \
+	X(SQLGetStmtAttr, (SQLHANDLE a, SQLINTEGER b, SQLPOINTER c, SQLINTEGER d, SQLINTEGER *e), (a, b, c, d, e)) \
+	X(SQLDriverConnect, (SQLHANDLE a, SQLHANDLE b, SQLCHAR *c, SQLSMALLINT d, SQLCHAR *e, SQLSMALLINT f, SQLSMALLINT *g, SQLUSMALLINT h), (a, b, c, d, e, f, g, h)) \
+	X(SQLDriverConnectW, (SQLHANDLE a, SQLHANDLE b, SQLWCHAR *c, SQLSMALLINT d, SQLWCHAR *e, SQLSMALLINT f, SQLSMALLINT *g, SQLUSMALLINT h), (a, b, c, d, e, f, g, h)) \
+	X(SQLDisconnect, (SQLHANDLE a), (a)) \
+	X(SQLEndTran, (SQLSMALLINT a, SQLHANDLE b, SQLSMALLINT c), (a, b, c)) \
+	X(SQLGetInfo, (SQLHANDLE a, SQLUSMALLINT b, SQLPOINTER c, SQLSMALLINT d, SQLSMALLINT *e), (a, b, c, d, e)) \
+	X(SQLGetInfoW, (SQLHANDLE a, SQLUSMALLINT b, SQLPOINTER c, SQLSMALLINT d, SQLSMALLINT *e), (a, b, c, d, e)) \
+	X(SQLPrepare, (SQLHANDLE a, SQLCHAR *b, SQLINTEGER c), (a, b, c)) \
+	X(SQLPrepareW, (SQLHANDLE a, SQLWCHAR *b, SQLINTEGER c), (a, b, c)) \
+	X(SQLExecDirect, (SQLHANDLE a, SQLCHAR *b, SQLINTEGER c), (a, b, c)) \
+	X(SQLExecDirectW, (SQLHANDLE a, SQLWCHAR *b, SQLINTEGER c), (a, b, c)) \
+	X(SQLExecute, (SQLHANDLE a), (a)) \
+	X(SQLCancel, (SQLHANDLE a), (a)) \
+	X(SQLCancelHandle, (SQLSMALLINT a, SQLHANDLE b), (a, b)) \
+	X(SQLParamData, (SQLHANDLE a, SQLPOINTER *b), (a, b)) \
+	X(SQLPutData, (SQLHANDLE a, SQLPOINTER b, SQLLEN c), (a, b, c)) \
+	X(SQLNumParams, (SQLHANDLE a, SQLSMALLINT *b), (a, b)) \
+	X(SQLDescribeParam, (SQLHANDLE a, SQLUSMALLINT b, SQLSMALLINT *c, SQLULEN *d, SQLSMALLINT *e, SQLSMALLINT *f), (a, b, c, d, e, f)) \
+	X(SQLBindParameter, (SQLHANDLE a, SQLUSMALLINT b, SQLSMALLINT c, SQLSMALLINT d, SQLSMALLINT e, SQLULEN f, SQLSMALLINT g, SQLPOINTER h, SQLLEN i, SQLLEN *j), (a, b, c, d, e, f, g, h, i, j)) \
+	X(SQLGetTypeInfo, (SQLHANDLE a, SQLSMALLINT b), (a, b)) \
+	X(SQLNumResultCols, (SQLHANDLE a, SQLSMALLINT *b), (a, b)) \
+	X(SQLDescribeCol, (SQLHANDLE a, SQLUSMALLINT b, SQLCHAR *c, SQLSMALLINT d, SQLSMALLINT *e, SQLSMALLINT *f, SQLULEN *g, SQLSMALLINT *h, SQLSMALLINT *i), (a, b, c, d, e, f, g, h, i)) \
+	X(SQLDescribeColW, (SQLHANDLE a, SQLUSMALLINT b, SQLWCHAR *c, SQLSMALLINT d, SQLSMALLINT *e, SQLSMALLINT *f, SQLULEN *g, SQLSMALLINT *h, SQLSMALLINT *i), (a, b, c, d, e, f, g, h, i)) \
+	X(SQLColAttribute, (SQLHANDLE a, SQLUSMALLINT b, SQLUSMALLINT c, SQLPOINTER d, SQLSMALLINT e, SQLSMALLINT *f, SQLPOINTER g), (a, b, c, d, e, f, g)) \
+	X(SQLColAttributeW, (SQLHANDLE a, SQLUSMALLINT b, SQLUSMALLINT c, SQLPOINTER d, SQLSMALLINT e, SQLSMALLINT *f, SQLPOINTER g), (a, b, c, d, e, f, g)) \
+	X(SQLBindCol, (SQLHANDLE a, SQLUSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLLEN e, SQLLEN *f), (a, b, c, d, e, f)) \
+	X(SQLFetch, (SQLHANDLE a), (a)) \
+	X(SQLFetchScroll, (SQLHANDLE a, SQLSMALLINT b, SQLLEN c), (a, b, c)) \
+	X(SQLGetData, (SQLHANDLE a, SQLUSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLLEN e, SQLLEN *f), (a, b, c, d, e, f)) \
+	X(SQLSetPos, (SQLHANDLE a, SQLULEN b, SQLUSMALLINT c, SQLUSMALLINT d), (a, b, c, d)) \
+	X(SQLBulkOperations, (SQLHANDLE a, SQLSMALLINT b), (a, b)) \
+	X(SQLMoreResults, (SQLHANDLE a), (a)) \
+	X(SQLRowCount, (SQLHANDLE a, SQLLEN *b), (a, b)) \
+	X(SQLSetCursorNameW, (SQLHANDLE a, SQLWCHAR *b, SQLSMALLINT c), (a, b, c)) \
+	X(SQLGetCursorNameW, (SQLHANDLE a, SQLWCHAR *b, SQLSMALLINT c, SQLSMALLINT *d), (a, b, c, d)) \
+	X(SQLGetDiagRec, (SQLSMALLINT a, SQLHANDLE b, SQLSMALLINT c, SQLCHAR *d, SQLINTEGER *e, SQLCHAR *f, SQLSMALLINT g, SQLSMALLINT *h), (a, b, c, d, e, f, g, h)) \
+	X(SQLGetDiagRecW, (SQLSMALLINT a, SQLHANDLE b, SQLSMALLINT c, SQLWCHAR *d, SQLINTEGER *e, SQLWCHAR *f, SQLSMALLINT g, SQLSMALLINT *h), (a, b, c, d, e, f, g, h)) \
+	X(SQLGetDiagField, (SQLSMALLINT a, SQLHANDLE b, SQLSMALLINT c, SQLSMALLINT d, SQLPOINTER e, SQLSMALLINT f, SQLSMALLINT *g), (a, b, c, d, e, f, g)) \
+	X(SQLGetDiagFieldW, (SQLSMALLINT a, SQLHANDLE b, SQLSMALLINT c, SQLSMALLINT d, SQLPOINTER e, SQLSMALLINT f, SQLSMALLINT *g), (a, b, c, d, e, f, g)) \
+	X(SQLGetDescField, (SQLHANDLE a, SQLSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLINTEGER e, SQLINTEGER *f), (a, b, c, d, e, f)) \
+	X(SQLGetDescFieldW, (SQLHANDLE a, SQLSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLINTEGER e, SQLINTEGER *f), (a, b, c, d, e, f)) \
+	X(SQLSetDescField, (SQLHANDLE a, SQLSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLINTEGER e), (a, b, c, d, e)) \
+	X(SQLSetDescFieldW, (SQLHANDLE a, SQLSMALLINT b, SQLSMALLINT c, SQLPOINTER d, SQLINTEGER e), (a, b, c, d, e)) \
+	X(SQLSetDescRec, (SQLHANDLE a, SQLSMALLINT b, SQLSMALLINT c, SQLSMALLINT d, SQLLEN e, SQLSMALLINT f, SQLSMALLINT g, SQLPOINTER h, SQLLEN *i, SQLLEN *j), (a, b, c, d, e, f, g, h, i, j)) \
+	X(SQLCopyDesc, (SQLHANDLE a, SQLHANDLE b), (a, b))
+
+#define ODBC_DEFINE(name, params, args) \
+	static SQLRETURN (*p_##name) params; \
+	SQLRETURN name params { \
+		if (p_##name == NULL) \
+			return ODBC_SQL_ERROR; \
+		return p_##name args; \
+	}
+
+ODBC_FUNCS(ODBC_DEFINE)
+
+static void *odbc_lib;
+
+// odbc_load loads the driver manager at path and resolves the ODBC API
+// from it. On failure it returns -1 with a message in err.
+int odbc_load(const char *path, char *err, int errlen) {
+	void *lib = dlopen(path, RTLD_NOW | RTLD_GLOBAL);
+	if (lib == NULL) {
+		snprintf(err, errlen, "%s", dlerror());
+		return -1;
+	}
+	if (dlsym(lib, "SQLAllocHandle") == NULL) {
+		snprintf(err, errlen, "%s: not an ODBC driver manager", path);
+		dlclose(lib);
+		return -1;
+	}
+#define ODBC_RESOLVE(name, params, args) \
+	*(void **)(&p_##name) = dlsym(lib, #name);
+	ODBC_FUNCS(ODBC_RESOLVE)
+#undef ODBC_RESOLVE
+	odbc_lib = lib;
+	return 0;
+}
+
+// odbc_dm_kind tells the loaded driver manager apart by what only one of
+// them has: 1 for unixODBC, 2 for iODBC, 0 if neither or both match.
+int odbc_dm_kind(void) {
+	int unixodbc = dlsym(odbc_lib, "uodbc_open_stats") != NULL;
+	int iodbc = dlsym(odbc_lib, "iodbc_version") != NULL;
+	if (!unixodbc) {
+		// SQL_ATTR_UNIXODBC_VERSION, an environment attribute of
+		// unixODBC only.
+		SQLHANDLE env = NULL;
+		char version[64];
+		if (SQLAllocHandle(1, NULL, &env) == 0 && env != NULL) {
+			SQLRETURN ret = SQLGetEnvAttr(env, 65003, version, sizeof(version), NULL);
+			unixodbc = ret == 0 || ret == 1;
+			SQLFreeHandle(1, env);
+		}
+	}
+	if (unixodbc == iodbc)
+		return 0;
+	return unixodbc ? 1 : 2;
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2012, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"odbc"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config describes an ODBC connection for NewConnector, as an
+// alternative to a connection string:
+//
+//	c, err := driver.NewConnector(driver.Config{DSN: "test", UID: "user", PWD: "secret"})
+//	db := sql.OpenDB(c)
+type Config struct {
+	// Connection string pieces. Either DSN or Driver should be set.
+	DSN      string
+	Driver   string
+	Server   string
+	Database string
+	UID      string
+	PWD      string
+
+	// Params holds the other connection string attributes.
+	Params map[string]string
+
+	LoginTimeout      time.Duration // SQL_ATTR_LOGIN_TIMEOUT, in whole seconds
+	ConnectionTimeout time.Duration // SQL_ATTR_CONNECTION_TIMEOUT, in whole seconds
+
+	// Attributes holds integer connection attributes, keyed by odbc.ATTR_*
+	// or driver-specific constants, set before connecting in ascending
+	// order of their keys, after LoginTimeout and ConnectionTimeout.
+	Attributes map[int]int
+
+	// InitStatements are executed in order on each new connection.
+	InitStatements []string
+
+	// OnConnect, if set, is called on each new connection after
+	// InitStatements. A non-nil error closes the connection.
+	OnConnect func(c *odbc.Connection) error
+
+	// Encoding, if set, switches the connection to the ANSI API of the
+	// driver with this client encoding, see odbc.Connection.SetEncoding.
+	Encoding odbc.Encoding
+
+	// DescribeParams binds parameters with the types the driver reports
+	// for them, see odbc.Statement.SetDescribeParams.
+	DescribeParams bool
+
+	// NarrowStrings binds string parameters as UTF-8 SQL_VARCHAR rather
+	// than SQL_WVARCHAR, see odbc.Statement.SetNarrowStrings.
+	NarrowStrings bool
+
+	// WarningHandler, if set, receives the warnings (SQL_SUCCESS_WITH_INFO
+	// diagnostics) of the connections, see odbc.WarningHandler.
+	WarningHandler odbc.WarningHandler
+
+	// PingQuery is run by Ping to check that the server is reachable.
+	// When empty, a lightweight SQLGetInfo probe is used instead.
+	PingQuery string
+}
+
+// ParseConfig parses an ODBC connection string such as
+// "DSN=test;UID=user;PWD={a;b}" into a Config. As in ODBC, the first of
+// several attributes with the same key wins.
+func ParseConfig(dsn string) (*Config, error) {
+	cfg := &Config{}
+	seen := make(map[string]bool)
+	for len(dsn) > 0 {
+		var key, value string
+		i := strings.IndexByte(dsn, '=')
+		if i < 0 {
+			if strings.TrimSpace(dsn) != "" {
+				return nil, errors.New("odbc: missing '=' in connection string")
+			}
+			break
+		}
+		key, dsn = strings.TrimSpace(dsn[:i]), dsn[i+1:]
+		if v := strings.TrimLeft(dsn, " \t"); strings.HasPrefix(v, "{") {
+			dsn = v
+			var b strings.Builder
+			j := 1
+			for {
+				if j >= len(dsn) {
+					return nil, errors.New("odbc: unterminated '{' in connection string")
+				}
+				if dsn[j] == '}' {
+					if j+1 < len(dsn) && dsn[j+1] == '}' {
+						b.WriteByte('}')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(dsn[j])
+				j++
+			}
+			value, dsn = b.String(), dsn[j+1:]
+			if i := strings.IndexByte(dsn, ';'); i >= 0 {
+				dsn = dsn[i+1:]
+			} else {
+				dsn = ""
+			}
+		} else if i := strings.IndexByte(dsn, ';'); i >= 0 {
+			value, dsn = dsn[:i], dsn[i+1:]
+		} else {
+			value, dsn = dsn, ""
+		}
+		if seen[strings.ToUpper(key)] {
+			continue
+		}
+		seen[strings.ToUpper(key)] = true
+		switch strings.ToUpper(key) {
+		case "":
+		case "DSN":
+			cfg.DSN = value
+		case "DRIVER":
+			cfg.Driver = value
+		case "SERVER":
+			cfg.Server = value
+		case "DATABASE":
+			cfg.Database = value
+		case "UID":
+			cfg.UID = value
+		case "PWD":
+			cfg.PWD = value
+		default:
+			if cfg.Params == nil {
+				cfg.Params = make(map[string]string)
+			}
+			cfg.Params[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+// ConnectionString formats cfg as an ODBC connection string. DSN comes
+// first, so that it takes precedence over Driver when both are set.
+func (cfg *Config) ConnectionString() string {
+	var b strings.Builder
+	write := func(key, value string) {
+		b.WriteString(key)
+		b.WriteByte('=')
+		if strings.ContainsAny(value, ";{}") || strings.TrimSpace(value) != value {
+			value = "{" + strings.Replace(value, "}", "}}", -1) + "}"
+		}
+		b.WriteString(value)
+		b.WriteByte(';')
+	}
+	add := func(key, value string) {
+		if value != "" {
+			write(key, value)
+		}
+	}
+	add("DSN", cfg.DSN)
+	add("DRIVER", cfg.Driver)
+	add("SERVER", cfg.Server)
+	add("DATABASE", cfg.Database)
+	add("UID", cfg.UID)
+	add("PWD", cfg.PWD)
+	keys := make([]string, 0, len(cfg.Params))
+	for k := range cfg.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// Given explicitly, so kept even when empty.
+		write(k, cfg.Params[k])
+	}
+	return b.String()
+}
+
+type connector struct {
+	d       *Driver
+	cfg     Config
+	connStr string
+}
+
+// NewConnector returns a driver.Connector for cfg, to be used with
+// sql.OpenDB. cfg is copied, later changes to its maps and slices have
+// no effect on the connector.
+func NewConnector(cfg Config) (driver.Connector, error) {
+	connStr := cfg.ConnectionString()
+	if connStr == "" {
+		return nil, errors.New("odbc: empty connection config")
+	}
+	if cfg.Params != nil {
+		params := make(map[string]string, len(cfg.Params))
+		for k, v := range cfg.Params {
+			params[k] = v
+		}
+		cfg.Params = params
+	}
+	if cfg.Attributes != nil {
+		attrs := make(map[int]int, len(cfg.Attributes))
+		for k, v := range cfg.Attributes {
+			attrs[k] = v
+		}
+		cfg.Attributes = attrs
+	}
+	cfg.InitStatements = append([]string(nil), cfg.InitStatements...)
+	return &connector{d: &Driver{}, cfg: cfg, connStr: connStr}, nil
+}
+
+// OpenConnector implements driver.DriverContext. The connection string
+// is passed to the driver manager unchanged.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	if strings.TrimSpace(dsn) == "" {
+		return nil, errors.New("odbc: empty connection string")
+	}
+	return &connector{d: d, connStr: dsn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.d
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cfg := &c.cfg
+	oc, err := odbc.NewConnection()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LoginTimeout > 0 {
+		if err := oc.SetAttr(odbc.ATTR_LOGIN_TIMEOUT, int(cfg.LoginTimeout/time.Second)); err != nil {
+			oc.Close()
+			return nil, err
+		}
+	}
+	if cfg.ConnectionTimeout > 0 {
+		if err := oc.SetAttr(odbc.ATTR_CONNECTION_TIMEOUT, int(cfg.ConnectionTimeout/time.Second)); err != nil {
+			oc.Close()
+			return nil, err
+		}
+	}
+	attrs := make([]int, 0, len(cfg.Attributes))
+	for attr := range cfg.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Ints(attrs)
+	for _, attr := range attrs {
+		if err := oc.SetAttr(attr, cfg.Attributes[attr]); err != nil {
+			oc.Close()
+			return nil, err
+		}
+	}
+	oc.SetEncoding(cfg.Encoding)
+	oc.SetDescribeParams(cfg.DescribeParams)
+	oc.SetNarrowStrings(cfg.NarrowStrings)
+	if err := oc.Connect(c.connStr); err != nil {
+		oc.Close()
+		return nil, err
+	}
+	if h := cfg.WarningHandler; h != nil {
+		for _, rec := range oc.Warnings() {
+			h(rec)
+		}
+		oc.SetWarningHandler(h)
+	}
+	for _, query := range cfg.InitStatements {
+		st, err := oc.ExecDirect(query)
+		if err != nil {
+			oc.Close()
+			return nil, err
+		}
+		st.Close()
+	}
+	if cfg.OnConnect != nil {
+		if err := cfg.OnConnect(oc); err != nil {
+			oc.Close()
+			return nil, err
+		}
+	}
+	return &conn{c: oc, pingQuery: cfg.PingQuery}, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2012, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConnectionString(t *testing.T) {
+	tests := []struct {
+		dsn, want string
+	}{
+		{"DSN=test", "DSN=test;"},
+		{"uid=user; pwd={a;b}}c} ;dsn=test", "DSN=test;UID=user;PWD={a;b}}c};"},
+		{"Driver=SQLite3;Database=/tmp/x.db;Timeout=;NoWCHAR=1", "DRIVER=SQLite3;DATABASE=/tmp/x.db;NoWCHAR=1;Timeout=;"},
+		{"DSN=test;App= padded ", "DSN=test;App={ padded };"},
+		{"DSN=test;PWD= {a;b} ;UID=user", "DSN=test;UID=user;PWD={a;b};"},
+		{"DRIVER=x;dsn=test;Driver=y;DSN=other", "DSN=test;DRIVER=x;"},
+	}
+	for _, tt := range tests {
+		cfg, err := ParseConfig(tt.dsn)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.dsn, err)
+		}
+		got := cfg.ConnectionString()
+		if got != tt.want {
+			t.Errorf("%q: connection string %q, want %q", tt.dsn, got, tt.want)
+		}
+		again, err := ParseConfig(got)
+		if err != nil {
+			t.Fatalf("%q: %v", got, err)
+		}
+		if !reflect.DeepEqual(again, cfg) {
+			t.Errorf("%q: parsed back as %+v, want %+v", got, again, cfg)
+		}
+	}
+}
+
+func TestOpenConnector(t *testing.T) {
+	d := &Driver{}
+	const dsn = "Driver=x;uid=user;DSN=test;Driver=y;PWD= {a;b}"
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.(*connector).connStr; got != dsn {
+		t.Errorf("connection string %q, want %q unchanged", got, dsn)
+	}
+	if _, err := d.OpenConnector(" "); err == nil {
+		t.Error("no error for an empty connection string")
+	}
+}
+
+func TestNewConnectorCopy(t *testing.T) {
+	cfg := Config{DSN: "test", Params: map[string]string{"App": "a"}, Attributes: map[int]int{1: 1}}
+	c, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Params["App"] = "b"
+	cfg.Attributes[1] = 2
+	got := c.(*connector)
+	if got.cfg.Params["App"] != "a" || got.cfg.Attributes[1] != 1 {
+		t.Errorf("connector sees later changes: %+v", got.cfg)
+	}
+	if got.connStr != "DSN=test;App=a;" {
+		t.Errorf("connection string %q", got.connStr)
+	}
+}
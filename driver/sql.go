@@ -5,9 +5,11 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"io"
 	"odbc"
 )
@@ -17,16 +19,31 @@ func init() {
 	sql.Register("odbc", d)
 }
 
-type Driver struct {
-}
+// Driver is the database/sql driver registered as "odbc". Options that
+// sql.Open cannot express in the connection string, such as a warning
+// handler, are set per connector with NewConnector and Config.
+type Driver struct{}
 
 func (d *Driver) Open(dsn string) (driver.Conn, error) {
-	c, err := odbc.Connect(dsn)
+	c, err := d.OpenConnector(dsn)
 	if err != nil {
 		return nil, err
 	}
-	conn := &conn{c: c}
-	return conn, nil
+	return c.Connect(context.Background())
+}
+
+// badConn returns err wrapped with driver.ErrBadConn when it reports a
+// lost connection, so that database/sql retries on a fresh connection.
+// It must only be used where the server cannot have performed the
+// operation yet.
+func badConn(err *odbc.ODBCError) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, odbc.ErrConnectionLost) {
+		return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+	}
+	return err
 }
 
 func (d *Driver) Close() error {
@@ -34,31 +51,110 @@ func (d *Driver) Close() error {
 }
 
 type conn struct {
-	c *odbc.Connection
-	t *tx
+	c   *odbc.Connection
+	t   *tx  // open transaction, if any
+	bad bool // set once the connection reported a lost link
+
+	pingQuery string
+}
+
+// check records a lost connection reported by err and returns err as an
+// error value.
+func (c *conn) check(err *odbc.ODBCError) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, odbc.ErrConnectionLost) {
+		c.bad = true
+	}
+	return err
+}
+
+// SetWarningHandler installs h for the statements prepared afterwards on
+// the connection. It can be reached through sql.Conn.Raw:
+//
+//	conn.Raw(func(dc interface{}) error {
+//		dc.(interface{ SetWarningHandler(odbc.WarningHandler) }).SetWarningHandler(h)
+//		return nil
+//	})
+func (c *conn) SetWarningHandler(h odbc.WarningHandler) {
+	c.c.SetWarningHandler(h)
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	if c.bad {
+		return nil, driver.ErrBadConn
+	}
 	st, err := c.c.Prepare(query)
 	if err != nil {
-		return nil, err
+		c.check(err)
+		return nil, badConn(err)
 	}
 
-	stmt := &stmt{st: st}
+	stmt := &stmt{c: c, st: st}
 	return stmt, nil
 }
 
 func (c *conn) Begin() (driver.Tx, error) {
+	if c.bad {
+		return nil, driver.ErrBadConn
+	}
 	if err := c.c.AutoCommit(false); err != nil {
-		return nil, err
+		c.check(err)
+		return nil, badConn(err)
+	}
+
+	c.t = &tx{c: c}
+	return c.t, nil
+}
+
+// Ping implements driver.Pinger.
+func (c *conn) Ping(ctx context.Context) error {
+	if c.bad {
+		return driver.ErrBadConn
+	}
+	if err := c.c.Ping(c.pingQuery); err != nil {
+		c.check(err)
+		return badConn(err)
 	}
+	return nil
+}
 
-	return &tx{c: c}, nil
+// IsValid implements driver.Validator. It does not contact the server.
+// Drivers that do not support SQL_ATTR_CONNECTION_DEAD (HYC00, HY092)
+// leave the connection valid until an operation reports it lost.
+func (c *conn) IsValid() bool {
+	if c.bad {
+		return false
+	}
+	dead, err := c.c.Dead()
+	if err != nil {
+		c.check(err)
+		return !c.bad
+	}
+	return !dead
+}
+
+// ResetSession implements driver.SessionResetter. It rolls back a
+// transaction left open and restores autocommit before the connection
+// is reused.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.bad {
+		return driver.ErrBadConn
+	}
+	if c.t != nil {
+		if err := c.t.Rollback(); err != nil {
+			return driver.ErrBadConn
+		}
+	}
+	return nil
 }
 
 func (c *conn) Close() error {
 	if c.c != nil {
-		return c.c.Close()
+		if err := c.c.Close(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -68,27 +164,39 @@ type tx struct {
 }
 
 func (t *tx) Commit() error {
-	err := t.c.c.Commit()
-	return err
+	if err := t.c.c.Commit(); err != nil {
+		return t.c.check(err)
+	}
+	return t.end()
 }
 
 func (t *tx) Rollback() error {
-	err := t.c.c.Rollback()
-	return err
+	if err := t.c.c.Rollback(); err != nil {
+		return t.c.check(err)
+	}
+	return t.end()
+}
+
+// end restores autocommit once the transaction is over.
+func (t *tx) end() error {
+	t.c.t = nil
+	err := t.c.c.AutoCommit(true)
+	return t.c.check(err)
 }
 
 type stmt struct {
+	c  *conn
 	st *odbc.Statement
 }
 
 func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
 	if err := s.st.Execute2(args); err != nil {
-		return nil, err
+		return nil, s.c.check(err)
 	}
 
 	rowsAffected, err := s.st.RowsAffected()
 	r := &result{rowsAffected: int64(rowsAffected)}
-	return r, err
+	return r, s.c.check(err)
 }
 
 func (s *stmt) NumInput() int {
@@ -97,7 +205,7 @@ func (s *stmt) NumInput() int {
 
 func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 	if err := s.st.Execute2(args); err != nil {
-		return nil, err
+		return nil, s.c.check(err)
 	}
 	rows := &rows{s: s}
 	return rows, nil
@@ -125,16 +233,12 @@ type rows struct {
 }
 
 func (r *rows) Columns() []string {
-	c, err := r.s.st.NumFields()
+	fields, err := r.s.st.Columns()
 	if err != nil {
 		return nil
 	}
-	columns := make([]string, c)
-	for i, _ := range columns {
-		f, err := r.s.st.FieldMetadata(i + 1)
-		if err != nil {
-			return nil
-		}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
 		columns[i] = f.Name
 	}
 	return columns
@@ -147,7 +251,7 @@ func (r *rows) Close() error {
 func (r *rows) Next(dest []driver.Value) error {
 	eof, err := r.s.st.FetchOne2(dest)
 	if err != nil {
-		return err
+		return r.s.c.check(err)
 	}
 	if eof {
 		return io.EOF
@@ -0,0 +1,40 @@
+// Copyright (c) 2012, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package driver
+
+import (
+	"database/sql/driver"
+	"errors"
+	"odbc"
+	"testing"
+)
+
+func TestBadConn(t *testing.T) {
+	if err := badConn(nil); err != nil {
+		t.Errorf("badConn(nil) = %#v, want nil", err)
+	}
+	for _, tt := range []struct {
+		state string
+		bad   bool
+	}{
+		{"08S01", true},
+		{"08003", true},
+		{"08001", true},
+		{"HYT00", false},
+		{"40001", false},
+		{"HY000", false},
+		{"", false},
+	} {
+		oerr := &odbc.ODBCError{SQLState: tt.state}
+		err := badConn(oerr)
+		if got := errors.Is(err, driver.ErrBadConn); got != tt.bad {
+			t.Errorf("%q: ErrBadConn = %v, want %v", tt.state, got, tt.bad)
+		}
+		var got *odbc.ODBCError
+		if !errors.As(err, &got) || got != oerr {
+			t.Errorf("%q: ODBCError not kept in %v", tt.state, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"fmt"
+)
+
+// Encoding converts between Go strings and the client character set of
+// a non-Unicode driver. Encodings of golang.org/x/text fit in with a
+// small adapter:
+//
+//	type xEncoding struct{ e encoding.Encoding }
+//
+//	func (x xEncoding) Encode(s string) ([]byte, error) { return x.e.NewEncoder().Bytes([]byte(s)) }
+//	func (x xEncoding) Decode(b []byte) (string, error) { return x.e.NewDecoder().String(string(b)) }
+//
+//	conn.SetEncoding(xEncoding{simplifiedchinese.GBK})
+type Encoding interface {
+	Encode(s string) ([]byte, error)
+	Decode(b []byte) (string, error)
+}
+
+// Latin1 is the ISO-8859-1 encoding.
+var Latin1 Encoding = latin1{}
+
+type latin1 struct{}
+
+func (latin1) Encode(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("character %q not in Latin-1", r)
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
+
+func (latin1) Decode(b []byte) (string, error) {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r), nil
+}
+
+// SetEncoding switches the connection to the ANSI entry points of the
+// driver, with SQL text, character parameters and character columns
+// converted with enc. It applies to Connect and to the statements
+// allocated afterwards; call it before Connect for legacy drivers that
+// only have the ANSI API. A nil enc restores the Unicode entry points.
+func (conn *Connection) SetEncoding(enc Encoding) {
+	conn.enc = enc
+}
+
+// encode converts s with enc, or to UTF-8 if enc is nil, and appends a
+// terminating NUL.
+func encode(enc Encoding, s string) ([]byte, *ODBCError) {
+	if enc == nil {
+		return []byte(s + "\x00"), nil
+	}
+	b, err := enc.Encode(s)
+	if err != nil {
+		return nil, &ODBCError{SQLState: "22021", ErrorMessage: err.Error()}
+	}
+	return append(b, 0), nil
+}
+
+// decode converts b, up to the first NUL, with enc, or from UTF-8 if enc
+// is nil.
+func decode(enc Encoding, b []byte) (string, *ODBCError) {
+	for i, c := range b {
+		if c == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	if enc == nil {
+		return string(b), nil
+	}
+	s, err := enc.Decode(b)
+	if err != nil {
+		return "", &ODBCError{SQLState: "22021", ErrorMessage: err.Error()}
+	}
+	return s, nil
+}
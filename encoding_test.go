@@ -0,0 +1,58 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLatin1(t *testing.T) {
+	for _, tt := range []struct {
+		s string
+		b []byte
+	}{
+		{"", []byte{}},
+		{"abc", []byte("abc")},
+		{"café", []byte{'c', 'a', 'f', 0xe9}},
+		{" ÿ\u0080", []byte{0xa0, 0xff, 0x80}},
+	} {
+		b, err := Latin1.Encode(tt.s)
+		if err != nil || !bytes.Equal(b, tt.b) {
+			t.Errorf("Encode(%q) = % x, %v, want % x", tt.s, b, err, tt.b)
+		}
+		if s, err := Latin1.Decode(tt.b); err != nil || s != tt.s {
+			t.Errorf("Decode(% x) = %q, %v, want %q", tt.b, s, err, tt.s)
+		}
+	}
+	for _, s := range []string{"€", "aĀ", "日本", "😀", "a\xffb"} {
+		if b, err := Latin1.Encode(s); err == nil {
+			t.Errorf("Encode(%q) = % x, want an error", s, b)
+		}
+	}
+}
+
+func TestEncodeNUL(t *testing.T) {
+	for _, enc := range []Encoding{nil, Latin1} {
+		// The terminating NUL is added, and counted in the length.
+		b, err := encode(enc, "é")
+		want := []byte{0xe9, 0}
+		if enc == nil {
+			want = []byte{0xc3, 0xa9, 0}
+		}
+		if err != nil || !bytes.Equal(b, want) {
+			t.Errorf("%v: encode = % x, %v, want % x", enc, b, err, want)
+		}
+		// decode stops at the first NUL, or takes the whole buffer.
+		for _, in := range [][]byte{b, b[:len(b)-1], append(b, 'x', 0)} {
+			if s, err := decode(enc, in); err != nil || s != "é" {
+				t.Errorf("%v: decode(% x) = %q, %v", enc, in, s, err)
+			}
+		}
+	}
+	if _, err := encode(Latin1, "€"); err == nil || err.SQLState != "22021" {
+		t.Errorf("encode(Latin1, €): %v, want 22021", err)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"sync"
+	"unsafe"
+)
+
+// ODBC versions an environment can declare, see EnvConfig.
+const (
+	ODBC_VERSION_3    = C.SQL_OV_ODBC3
+	ODBC_VERSION_3_80 = C.SQL_OV_ODBC3_80
+)
+
+// EnvConfig configures a new Environment.
+type EnvConfig struct {
+	// Version is the ODBC version declared to the driver manager,
+	// ODBC_VERSION_3 when zero.
+	Version int
+
+	// Pooling is one of the CP_* modes and PoolMatch one of the CP_*_MATCH
+	// values. Pooling is a process-wide setting of the driver manager; it
+	// is only applied when not CP_OFF.
+	Pooling   int
+	PoolMatch int
+
+	// TraceFile, when set, turns on driver manager tracing to that file
+	// for every connection of the environment.
+	TraceFile string
+}
+
+// Environment is an ODBC environment handle, from which connections are
+// allocated. Most programs use the default environment implicitly via
+// Connect; NewEnvironment allows several with different settings.
+type Environment struct {
+	handle C.SQLHANDLE
+	cfg    EnvConfig
+
+	mu     sync.Mutex
+	conns  int  // connections allocated and not yet closed
+	used   bool // set once a connection has been allocated
+	closed bool
+}
+
+var (
+	envMu         sync.Mutex
+	defaultEnv    *Environment
+	defaultConfig = EnvConfig{Version: ODBC_VERSION_3}
+
+	dmMu     sync.Mutex
+	dmPath   string
+	dmLoaded bool
+)
+
+// SetDriverManager sets the path of the driver manager library, e.g.
+// "libiodbc.so.2", for programs built with the odbc_dlopen tag, which
+// load it at run time. It must be called before the first environment
+// is allocated, and has no effect in other builds.
+func SetDriverManager(path string) {
+	dmMu.Lock()
+	dmPath = path
+	dmMu.Unlock()
+}
+
+// NewEnvironment allocates an ODBC environment configured by cfg.
+func NewEnvironment(cfg EnvConfig) (*Environment, *ODBCError) {
+	if err := loadDriverManager(); err != nil {
+		return nil, err
+	}
+	if cfg.Version == 0 {
+		cfg.Version = ODBC_VERSION_3
+	}
+	if cfg.Pooling != CP_OFF {
+		ret := C.SQLSetEnvAttr(C.SQLHENV(nil), C.SQL_ATTR_CONNECTION_POOLING, C.SQLPOINTER(unsafe.Pointer(uintptr(cfg.Pooling))), C.SQL_IS_UINTEGER)
+		if !Success(ret) {
+			return nil, &ODBCError{SQLState: "HY024", ErrorMessage: "invalid connection pooling mode"}
+		}
+	}
+	env := &Environment{cfg: cfg}
+	ret := C.SQLAllocHandle(C.SQL_HANDLE_ENV, nil, &env.handle)
+	if !Success(ret) {
+		if env.handle == nil {
+			return nil, &ODBCError{SQLState: "HY000", ErrorMessage: "cannot allocate ODBC environment"}
+		}
+		err := FormatError(C.SQL_HANDLE_ENV, env.handle)
+		return nil, err
+	}
+	ret = C.SQLSetEnvAttr(C.SQLHENV(env.handle), C.SQL_ATTR_ODBC_VERSION, C.SQLPOINTER(unsafe.Pointer(uintptr(cfg.Version))), C.SQLINTEGER(0))
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_ENV, env.handle)
+		C.SQLFreeHandle(C.SQL_HANDLE_ENV, env.handle)
+		return nil, err
+	}
+	if cfg.Pooling != CP_OFF {
+		ret = C.SQLSetEnvAttr(C.SQLHENV(env.handle), C.SQL_ATTR_CP_MATCH, C.SQLPOINTER(unsafe.Pointer(uintptr(cfg.PoolMatch))), C.SQL_IS_UINTEGER)
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_ENV, env.handle)
+			C.SQLFreeHandle(C.SQL_HANDLE_ENV, env.handle)
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// DefaultEnvironment returns the environment used by Connect and
+// NewConnection, allocating it on first use.
+func DefaultEnvironment() (*Environment, *ODBCError) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if defaultEnv == nil {
+		env, err := NewEnvironment(defaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		defaultEnv = env
+		Genv = env.handle
+	}
+	return defaultEnv, nil
+}
+
+// ConfigureDefaultEnvironment sets the configuration of the default
+// environment. It must be called before the first connection.
+func ConfigureDefaultEnvironment(cfg EnvConfig) *ODBCError {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if defaultEnv != nil {
+		defaultEnv.mu.Lock()
+		used := defaultEnv.used
+		defaultEnv.mu.Unlock()
+		if used {
+			return &ODBCError{SQLState: "HY010", ErrorMessage: "the default environment must be configured before the first connection"}
+		}
+		if err := defaultEnv.Close(); err != nil {
+			return err
+		}
+		defaultEnv = nil
+		Genv = nil
+	}
+	defaultConfig = cfg
+	return nil
+}
+
+// Shutdown closes the default environment. Its handle is freed once the
+// last of its connections is closed; a later Connect allocates a new one.
+func Shutdown() *ODBCError {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if defaultEnv == nil {
+		return nil
+	}
+	env := defaultEnv
+	defaultEnv = nil
+	Genv = nil
+	return env.Close()
+}
+
+// Connect connects to dsn with a connection of env.
+func (env *Environment) Connect(dsn string) (*Connection, *ODBCError) {
+	conn, err := env.NewConnection()
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.Connect(dsn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// NewConnection allocates a connection of env that is not connected yet,
+// see the package-level NewConnection.
+func (env *Environment) NewConnection() (*Connection, *ODBCError) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return nil, &ODBCError{SQLState: "HY010", ErrorMessage: "environment is closed"}
+	}
+	var h C.SQLHANDLE
+	ret := C.SQLAllocHandle(C.SQL_HANDLE_DBC, env.handle, &h)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_ENV, env.handle)
+		return nil, err
+	}
+	conn := &Connection{Dbc: h, env: env}
+	if env.cfg.TraceFile != "" {
+		ret = C.SQLSetConnectAttrW(C.SQLHDBC(h), C.SQL_ATTR_TRACEFILE, C.SQLPOINTER(unsafe.Pointer(wstr(stringToWide(env.cfg.TraceFile)))), C.SQL_NTS)
+		if Success(ret) {
+			ret = C.SQLSetConnectAttr(C.SQLHDBC(h), C.SQL_ATTR_TRACE, C.SQLPOINTER(unsafe.Pointer(uintptr(C.SQL_OPT_TRACE_ON))), C.SQL_IS_UINTEGER)
+		}
+		if !Success(ret) {
+			err := FormatError(C.SQL_HANDLE_DBC, h)
+			C.SQLFreeHandle(C.SQL_HANDLE_DBC, h)
+			return nil, err
+		}
+	}
+	env.conns++
+	env.used = true
+	return conn, nil
+}
+
+// release is called when a connection of env has been freed.
+func (env *Environment) release() *ODBCError {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.conns--
+	if env.closed && env.conns == 0 {
+		return env.free()
+	}
+	return nil
+}
+
+// Close frees the environment handle, immediately if it has no open
+// connections, otherwise when the last one is closed. No connection can
+// be allocated from env afterwards.
+func (env *Environment) Close() *ODBCError {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if env.closed {
+		return nil
+	}
+	env.closed = true
+	if env.conns == 0 {
+		return env.free()
+	}
+	return nil
+}
+
+func (env *Environment) free() *ODBCError {
+	ret := C.SQLFreeHandle(C.SQL_HANDLE_ENV, env.handle)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_ENV, env.handle)
+		return err
+	}
+	env.handle = nil
+	return nil
+}
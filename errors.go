@@ -0,0 +1,74 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error classes matched by ODBCError through errors.Is, e.g.
+//
+//	if errors.Is(err, odbc.ErrConstraintViolation) { ... }
+var (
+	ErrConstraintViolation = errors.New("odbc: integrity constraint violation") // 23xxx
+	ErrDeadlock            = errors.New("odbc: deadlock")                       // 40001, 40P01
+	ErrSerialization       = errors.New("odbc: serialization failure")          // 40001
+	ErrTimeout             = errors.New("odbc: timeout expired")                // HYT00, HYT01
+	ErrConnectionLost      = errors.New("odbc: connection lost")                // 08xxx
+	ErrTruncation          = errors.New("odbc: data truncated")                 // 01004, 22001
+	ErrOutOfRange          = errors.New("odbc: numeric value out of range")     // 22003
+	ErrUnsupportedType     = errors.New("odbc: unsupported parameter type")     // HY004
+	ErrLimitExceeded       = errors.New("odbc: program limit exceeded")         // 54xxx, see Rows
+)
+
+// classes maps each error class to a test on SQLSTATE.
+var classes = map[error]func(state string) bool{
+	ErrConstraintViolation: func(state string) bool {
+		return strings.HasPrefix(state, "23")
+	},
+	ErrDeadlock: func(state string) bool {
+		return state == "40001" || state == "40P01"
+	},
+	ErrSerialization: func(state string) bool {
+		return state == "40001"
+	},
+	ErrTimeout: func(state string) bool {
+		return state == "HYT00" || state == "HYT01"
+	},
+	ErrConnectionLost: func(state string) bool {
+		return strings.HasPrefix(state, "08")
+	},
+	ErrTruncation: func(state string) bool {
+		return state == "01004" || state == "22001"
+	},
+	ErrOutOfRange: func(state string) bool {
+		return state == "22003"
+	},
+	ErrUnsupportedType: func(state string) bool {
+		return state == "HY004"
+	},
+	ErrLimitExceeded: func(state string) bool {
+		return strings.HasPrefix(state, "54")
+	},
+}
+
+// Is reports whether any diagnostic record of e belongs to the error
+// class target, one of the Err* variables of this package.
+func (e *ODBCError) Is(target error) bool {
+	match, ok := classes[target]
+	if !ok || e == nil {
+		return false
+	}
+	if match(e.SQLState) {
+		return true
+	}
+	for _, rec := range e.Records {
+		if match(rec.SQLState) {
+			return true
+		}
+	}
+	return false
+}
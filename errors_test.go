@@ -0,0 +1,63 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorClasses(t *testing.T) {
+	all := []error{
+		ErrConstraintViolation, ErrDeadlock, ErrSerialization, ErrTimeout, ErrConnectionLost,
+		ErrTruncation, ErrOutOfRange, ErrUnsupportedType, ErrLimitExceeded,
+	}
+	tests := []struct {
+		state string
+		want  []error
+	}{
+		{"23000", []error{ErrConstraintViolation}},
+		{"23505", []error{ErrConstraintViolation}},
+		{"40001", []error{ErrDeadlock, ErrSerialization}},
+		{"40P01", []error{ErrDeadlock}},
+		{"HYT00", []error{ErrTimeout}},
+		{"HYT01", []error{ErrTimeout}},
+		{"08S01", []error{ErrConnectionLost}},
+		{"08003", []error{ErrConnectionLost}},
+		{"01004", []error{ErrTruncation}},
+		{"22001", []error{ErrTruncation}},
+		{"22003", []error{ErrOutOfRange}},
+		{"HY004", []error{ErrUnsupportedType}},
+		{"54000", []error{ErrLimitExceeded}},
+		{"42S02", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		for _, target := range all {
+			want := false
+			for _, w := range tt.want {
+				want = want || w == target
+			}
+			// The class is found in the first record and in later ones.
+			first := &ODBCError{SQLState: tt.state}
+			later := &ODBCError{SQLState: "HY000", Records: []DiagRecord{{SQLState: "HY000"}, {SQLState: tt.state}}}
+			for _, err := range []*ODBCError{first, later} {
+				if got := errors.Is(err, target); got != want {
+					t.Errorf("%q with records %v: errors.Is(%v) = %v, want %v", err.SQLState, err.Records, target, got, want)
+				}
+			}
+		}
+	}
+
+	var err *ODBCError
+	for _, target := range all {
+		if errors.Is(err, target) {
+			t.Errorf("nil *ODBCError matches %v", target)
+		}
+	}
+	if (&ODBCError{SQLState: "23000"}).Is(errors.New("other")) {
+		t.Error("matches an error that is not a class")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build odbc_dlopen && !windows
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+int odbc_load(const char *path, char *err, int errlen);
+int odbc_dm_kind(void);
+*/
+import "C"
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// loadDriverManager loads the driver manager library set by
+// SetDriverManager, or by the ODBC_DRIVER_MANAGER environment variable,
+// or else the first of the usual unixODBC and iODBC libraries found.
+func loadDriverManager() *ODBCError {
+	dmMu.Lock()
+	defer dmMu.Unlock()
+	if dmLoaded {
+		return nil
+	}
+	paths := []string{"libodbc.so.2", "libodbc.so", "libiodbc.so.2", "libiodbc.so"}
+	if runtime.GOOS == "darwin" {
+		paths = []string{"libodbc.2.dylib", "libodbc.dylib", "libiodbc.2.dylib", "libiodbc.dylib"}
+	}
+	if p := os.Getenv("ODBC_DRIVER_MANAGER"); p != "" {
+		paths = []string{p}
+	}
+	if dmPath != "" {
+		paths = []string{dmPath}
+	}
+	msg := make([]C.char, INFO_BUFFER_LEN)
+	var errs []string
+	for _, p := range paths {
+		cpath := C.CString(p)
+		ret := C.odbc_load(cpath, &msg[0], INFO_BUFFER_LEN)
+		C.free(unsafe.Pointer(cpath))
+		if ret == 0 {
+			size, err := driverManagerWcharSize(p)
+			if err != nil {
+				return err
+			}
+			wcharSize = size
+			dmLoaded = true
+			return nil
+		}
+		errs = append(errs, C.GoString(&msg[0]))
+	}
+	return &ODBCError{SQLState: "HY000", ErrorMessage: "cannot load the ODBC driver manager: " + strings.Join(errs, "; ")}
+}
+
+// driverManagerWcharSize returns the size of SQLWCHAR in the driver
+// manager loaded from path, which need not match the headers built
+// against: 2 for unixODBC, 4 for iODBC, which uses wchar_t. The
+// ODBC_WCHAR_SIZE environment variable, 2 or 4, overrides it, e.g. for
+// a unixODBC built with SQL_WCHART_CONVERT.
+func driverManagerWcharSize(path string) (int, *ODBCError) {
+	if s := os.Getenv("ODBC_WCHAR_SIZE"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || (n != 2 && n != 4) {
+			return 0, &ODBCError{SQLState: "HY000", ErrorMessage: "ODBC_WCHAR_SIZE must be 2 or 4, not " + s}
+		}
+		return n, nil
+	}
+	switch C.odbc_dm_kind() {
+	case 1:
+		return 2, nil
+	case 2:
+		return 4, nil
+	}
+	return 0, &ODBCError{SQLState: "HY000", ErrorMessage: "cannot tell whether " + path + " is unixODBC or iODBC; set ODBC_WCHAR_SIZE to the size of its SQLWCHAR, 2 or 4"}
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !odbc_dlopen || windows
+
+package odbc
+
+// loadDriverManager does nothing, the driver manager is linked in.
+func loadDriverManager() *ODBCError {
+	return nil
+}
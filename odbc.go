@@ -5,9 +5,10 @@
 package odbc
 
 /*
-#cgo darwin LDFLAGS: -lodbc
-#cgo freebsd LDFLAGS: -lodbc
-#cgo linux LDFLAGS: -lodbc
+#cgo darwin,!odbc_dlopen LDFLAGS: -lodbc
+#cgo freebsd,!odbc_dlopen LDFLAGS: -lodbc
+#cgo linux,!odbc_dlopen LDFLAGS: -lodbc
+#cgo linux,odbc_dlopen LDFLAGS: -ldl
 #cgo windows LDFLAGS: -lodbc32
 
 #include <stdio.h>
@@ -43,9 +44,11 @@ SQLRETURN _SQLColAttribute (
 */
 import "C"
 import (
+	"context"
 	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -55,13 +58,35 @@ const (
 	INFO_BUFFER_LEN = 256
 )
 
+// Connection attributes, see Connection.SetAttr.
+const (
+	ATTR_ACCESS_MODE        = C.SQL_ATTR_ACCESS_MODE
+	ATTR_AUTOCOMMIT         = C.SQL_ATTR_AUTOCOMMIT
+	ATTR_CONNECTION_TIMEOUT = C.SQL_ATTR_CONNECTION_TIMEOUT
+	ATTR_LOGIN_TIMEOUT      = C.SQL_ATTR_LOGIN_TIMEOUT
+	ATTR_PACKET_SIZE        = C.SQL_ATTR_PACKET_SIZE
+	ATTR_TXN_ISOLATION      = C.SQL_ATTR_TXN_ISOLATION
+)
+
 var (
+	// Genv is the handle of the default environment once it has been
+	// allocated, see DefaultEnvironment.
 	Genv C.SQLHANDLE
 )
 
 type Connection struct {
 	Dbc       C.SQLHANDLE
 	connected bool
+	env       *Environment
+	enc       Encoding // ANSI mode client encoding, see SetEncoding
+
+	describeParams bool
+	narrowStrings  bool
+	maxWVarchar    int       // see wvarcharMax
+	typeInfo       sync.Once // loads maxWVarchar
+
+	warnings       []DiagRecord
+	warningHandler WarningHandler
 }
 
 type Statement struct {
@@ -69,13 +94,32 @@ type Statement struct {
 	prepared   bool
 	scrollable bool
 
-	handle C.SQLHANDLE
+	conn     *Connection
+	handle   C.SQLHANDLE
+	bookmark unsafe.Pointer  // C memory for SQL_ATTR_FETCH_BOOKMARK_PTR
+	getData  *getDataBuf     // C memory for GetField
+	params   map[int]*cValue // bound parameter buffers, by index
+	enc      Encoding
+
+	describeParams bool
+	paramDescs     []*paramDesc // cached by describeParam, reset by Prepare
+	narrowStrings  bool
+	fields         []Field // result set columns, see Columns
+
+	warnings       []DiagRecord
+	warningHandler WarningHandler
 }
 
 type ODBCError struct {
-	SQLState     string
-	NativeError  int
-	ErrorMessage string
+	SQLState     string // SQLSTATE of the first record
+	NativeError  int    // native error of the first record
+	ErrorMessage string // messages of all records
+
+	// Records holds every diagnostic record, in the order returned by the
+	// driver. RowCount and DynamicFunction come from the diagnostic header.
+	Records         []DiagRecord
+	RowCount        int
+	DynamicFunction string
 }
 
 func (e *ODBCError) Error() string {
@@ -89,65 +133,96 @@ func (e *ODBCError) String() string {
 	return ""
 }
 
-func initEnv() (err *ODBCError) {
-	ret := C.SQLAllocHandle(C.SQL_HANDLE_ENV, nil, &Genv)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_ENV, Genv)
-		return err
+func Connect(dsn string, params ...interface{}) (conn *Connection, err *ODBCError) {
+	if conn, err = NewConnection(); err != nil {
+		return nil, err
 	}
-	ret = C.SQLSetEnvAttr(C.SQLHENV(Genv), C.SQL_ATTR_ODBC_VERSION, C.SQLPOINTER(unsafe.Pointer(uintptr(C.SQL_OV_ODBC3))), C.SQLINTEGER(0))
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_ENV, Genv)
-		return err
+	if err = conn.Connect(dsn); err != nil {
+		conn.Close()
+		return nil, err
 	}
-	return nil
+	return conn, nil
 }
 
-func Connect(dsn string, params ...interface{}) (conn *Connection, err *ODBCError) {
-	var h C.SQLHANDLE
-	ret := C.SQLAllocHandle(C.SQL_HANDLE_DBC, Genv, &h)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_DBC, h)
+// NewConnection allocates a connection that is not connected yet, so
+// that attributes which must be set beforehand (login timeout, packet
+// size) can be applied with SetAttr before calling Connect.
+func NewConnection() (*Connection, *ODBCError) {
+	env, err := DefaultEnvironment()
+	if err != nil {
 		return nil, err
 	}
+	return env.NewConnection()
+}
 
+func (conn *Connection) Connect(dsn string) *ODBCError {
 	var stringLength2 C.SQLSMALLINT
-	outBuf := make([]byte, BUFFER_SIZE*2)
-	outConnectionString := (*C.SQLWCHAR)(unsafe.Pointer(&outBuf[0]))
-
-	ret = C.SQLDriverConnectW(C.SQLHDBC(h),
-		C.SQLHWND(unsafe.Pointer(uintptr(0))),
-		(*C.SQLWCHAR)(unsafe.Pointer(StringToUTF16Ptr(dsn))),
-		C.SQL_NTS,
-		outConnectionString,
-		BUFFER_SIZE,
-		&stringLength2,
-		C.SQL_DRIVER_NOPROMPT)
+	var ret C.SQLRETURN
+	if conn.enc != nil {
+		adsn, err := encode(conn.enc, dsn)
+		if err != nil {
+			return err
+		}
+		outBuf := make([]byte, BUFFER_SIZE)
+		ret = C.SQLDriverConnect(C.SQLHDBC(conn.Dbc),
+			C.SQLHWND(unsafe.Pointer(uintptr(0))),
+			(*C.SQLCHAR)(unsafe.Pointer(&adsn[0])),
+			C.SQL_NTS,
+			(*C.SQLCHAR)(unsafe.Pointer(&outBuf[0])),
+			BUFFER_SIZE,
+			&stringLength2,
+			C.SQL_DRIVER_NOPROMPT)
+	} else {
+		outBuf := wideBuffer(BUFFER_SIZE)
+		ret = C.SQLDriverConnectW(C.SQLHDBC(conn.Dbc),
+			C.SQLHWND(unsafe.Pointer(uintptr(0))),
+			wstr(stringToWide(dsn)),
+			C.SQL_NTS,
+			wstr(outBuf),
+			BUFFER_SIZE,
+			&stringLength2,
+			C.SQL_DRIVER_NOPROMPT)
+	}
 
 	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_DBC, h)
-		return nil, err
+		err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return err
 	}
-	return &Connection{Dbc: h, connected: true}, nil
+	conn.connected = true
+	conn.checkInfo(ret)
+	return nil
+}
+
+// SetAttr sets an integer connection attribute, one of the ATTR_*
+// constants or a driver-specific attribute.
+func (conn *Connection) SetAttr(attr int, value int) *ODBCError {
+	ret := C.SQLSetConnectAttr(C.SQLHDBC(conn.Dbc), C.SQLINTEGER(attr), C.SQLPOINTER(unsafe.Pointer(uintptr(value))), C.SQL_IS_INTEGER)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return err
+	}
+	return nil
 }
 
 func (conn *Connection) ExecDirect(sql string) (stmt *Statement, err *ODBCError) {
 	if stmt, err = conn.newStmt(); err != nil {
 		return nil, err
 	}
-	wsql := StringToUTF16Ptr(sql)
-	ret := C.SQLExecDirectW(C.SQLHSTMT(stmt.handle), (*C.SQLWCHAR)(unsafe.Pointer(wsql)), C.SQL_NTS)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+	if err = stmt.ExecDirect(sql); err != nil {
 		stmt.Close()
 		return nil, err
 	}
-	stmt.executed = true
 	return stmt, nil
 }
 
 func (conn *Connection) newStmt() (*Statement, *ODBCError) {
-	stmt := &Statement{}
+	stmt := &Statement{
+		conn:           conn,
+		enc:            conn.enc,
+		describeParams: conn.describeParams,
+		narrowStrings:  conn.narrowStrings,
+		warningHandler: conn.warningHandler,
+	}
 
 	ret := C.SQLAllocHandle(C.SQL_HANDLE_STMT, conn.Dbc, &stmt.handle)
 	if !Success(ret) {
@@ -157,19 +232,22 @@ func (conn *Connection) newStmt() (*Statement, *ODBCError) {
 	return stmt, nil
 }
 
+// NewStatement allocates a statement that is neither prepared nor
+// executed, so that attributes which must be set beforehand (cursor
+// type, concurrency, cursor name) can be applied.
+func (conn *Connection) NewStatement() (*Statement, *ODBCError) {
+	return conn.newStmt()
+}
+
 func (conn *Connection) Prepare(sql string, params ...interface{}) (*Statement, *ODBCError) {
-	wsql := StringToUTF16Ptr(sql)
 	stmt, err := conn.newStmt()
 	if err != nil {
 		return nil, err
 	}
-	ret := C.SQLPrepareW(C.SQLHSTMT(stmt.handle), (*C.SQLWCHAR)(unsafe.Pointer(wsql)), C.SQLINTEGER(len(sql)))
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+	if err = stmt.Prepare(sql); err != nil {
 		stmt.Close()
 		return nil, err
 	}
-	stmt.prepared = true
 	return stmt, nil
 }
 
@@ -178,6 +256,7 @@ func (conn *Connection) Commit() (err *ODBCError) {
 	if !Success(ret) {
 		err = FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
 	}
+	conn.checkInfo(ret)
 	return
 }
 
@@ -208,6 +287,7 @@ func (conn *Connection) Rollback() (err *ODBCError) {
 	if !Success(ret) {
 		err = FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
 	}
+	conn.checkInfo(ret)
 	return
 }
 
@@ -259,23 +339,115 @@ func (conn *Connection) ClientInfo() (string, string, string, *ODBCError) {
 	return drv_name, drv_odbc_ver, drv_ver, nil
 }
 
-func (conn *Connection) Close() *ODBCError {
-	if conn.connected {
-		ret := C.SQLDisconnect(C.SQLHDBC(conn.Dbc))
+// Dead reports whether the driver has detected that the connection to
+// the server is lost (SQL_ATTR_CONNECTION_DEAD). It does not contact the
+// server.
+func (conn *Connection) Dead() (bool, *ODBCError) {
+	var dead C.SQLINTEGER
+	ret := C.SQLGetConnectAttr(C.SQLHDBC(conn.Dbc), C.SQL_ATTR_CONNECTION_DEAD, C.SQLPOINTER(unsafe.Pointer(&dead)), C.SQL_IS_INTEGER, nil)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return false, err
+	}
+	return dead == C.SQL_CD_TRUE, nil
+}
+
+// Ping checks that the server is reachable by running query and reading
+// its results, or, if query is empty, with a SQLGetInfo probe.
+func (conn *Connection) Ping(query string) *ODBCError {
+	if query == "" {
+		var info_len C.SQLSMALLINT
+		p := make([]byte, INFO_BUFFER_LEN)
+		ret := C.SQLGetInfo(C.SQLHDBC(conn.Dbc), C.SQL_DATABASE_NAME, C.SQLPOINTER(unsafe.Pointer(&p[0])), INFO_BUFFER_LEN, &info_len)
 		if !Success(ret) {
 			err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
 			return err
 		}
-		ret = C.SQLFreeHandle(C.SQL_HANDLE_DBC, conn.Dbc)
-		if !Success(ret) {
-			err := FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+		return nil
+	}
+	stmt, err := conn.ExecDirect(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for {
+		ok, err := stmt.Fetch()
+		if err != nil {
 			return err
 		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// Close disconnects and frees the connection. The handle is freed and
+// given back to its environment even if disconnecting fails, in which
+// case the first error is returned. Closing a closed connection does
+// nothing.
+func (conn *Connection) Close() *ODBCError {
+	if conn.Dbc == nil {
+		return nil
+	}
+	var err *ODBCError
+	if conn.connected {
+		ret := C.SQLDisconnect(C.SQLHDBC(conn.Dbc))
+		if !Success(ret) {
+			err = FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+			// An open transaction (25000) keeps the connection up.
+			C.SQLEndTran(C.SQL_HANDLE_DBC, conn.Dbc, C.SQL_ROLLBACK)
+			C.SQLDisconnect(C.SQLHDBC(conn.Dbc))
+		}
 		conn.connected = false
 	}
+	ret := C.SQLFreeHandle(C.SQL_HANDLE_DBC, conn.Dbc)
+	if !Success(ret) && err == nil {
+		err = FormatError(C.SQL_HANDLE_DBC, conn.Dbc)
+	}
+	conn.Dbc = nil
+	if conn.env != nil {
+		if rerr := conn.env.release(); err == nil {
+			err = rerr
+		}
+		conn.env = nil
+	}
+	return err
+}
+
+func (stmt *Statement) Prepare(sql string) *ODBCError {
+	stmt.freeParams()
+	stmt.paramDescs = nil
+	stmt.fields = nil
+	var ret C.SQLRETURN
+	if stmt.enc != nil {
+		asql, err := encode(stmt.enc, sql)
+		if err != nil {
+			return err
+		}
+		csql := C.CBytes(asql)
+		defer C.free(csql)
+		ret = stmt.wait(func() C.SQLRETURN {
+			return C.SQLPrepare(C.SQLHSTMT(stmt.handle), (*C.SQLCHAR)(csql), C.SQL_NTS)
+		})
+	} else {
+		csql := C.CBytes(stringToWide(sql))
+		defer C.free(csql)
+		ret = stmt.wait(func() C.SQLRETURN {
+			return C.SQLPrepareW(C.SQLHSTMT(stmt.handle), (*C.SQLWCHAR)(csql), C.SQL_NTS)
+		})
+	}
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return err
+	}
+	stmt.prepared = true
 	return nil
 }
 
+func (stmt *Statement) ExecDirect(sql string) *ODBCError {
+	return stmt.ExecDirectContext(context.Background(), sql)
+}
+
 func (stmt *Statement) RowsAffected() (int, *ODBCError) {
 	var nor C.SQLLEN
 	ret := C.SQLRowCount(C.SQLHSTMT(stmt.handle), &nor)
@@ -296,53 +468,66 @@ func (stmt *Statement) Cancel() *ODBCError {
 }
 
 func (stmt *Statement) NumParams() int {
-	var cParams C.SQLSMALLINT
-	ret := C.SQLNumParams(C.SQLHSTMT(stmt.handle), &cParams)
-	if !Success(ret) {
+	n, err := stmt.numParams()
+	if err != nil {
 		return -1
 	}
-	return int(cParams)
+	return n
+}
+
+func (stmt *Statement) numParams() (int, *ODBCError) {
+	n := (*C.SQLSMALLINT)(cmalloc(unsafe.Sizeof(C.SQLSMALLINT(0))))
+	defer C.free(unsafe.Pointer(n))
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLNumParams(C.SQLHSTMT(stmt.handle), n)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return -1, err
+	}
+	return int(*n), nil
 }
 
 func (stmt *Statement) Execute(params ...interface{}) *ODBCError {
+	return stmt.ExecuteContext(context.Background(), params...)
+}
+
+func (stmt *Statement) Execute2(params []driver.Value) *ODBCError {
+	var args []interface{}
 	if params != nil {
-		var cParams C.SQLSMALLINT
-		ret := C.SQLNumParams(C.SQLHSTMT(stmt.handle), &cParams)
-		if !Success(ret) {
-			err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
-			return err
-		}
-		for i := 0; i < int(cParams); i++ {
-			stmt.BindParam(i+1, params[i])
+		args = make([]interface{}, len(params))
+		for i, v := range params {
+			args[i] = v
 		}
 	}
-	ret := C.SQLExecute(C.SQLHSTMT(stmt.handle))
-	if ret == C.SQL_NEED_DATA {
-		// TODO
-		//		send_data(stmt)
-	} else if ret == C.SQL_NO_DATA {
-		// Execute NO DATA
-	} else if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
-		return err
-	}
-	stmt.executed = true
-	return nil
+	return stmt.Execute(args...)
 }
 
-func (stmt *Statement) Execute2(params []driver.Value) *ODBCError {
+func (stmt *Statement) bindParams(params []interface{}) *ODBCError {
 	if params != nil {
-		var cParams C.SQLSMALLINT
-		ret := C.SQLNumParams(C.SQLHSTMT(stmt.handle), &cParams)
-		if !Success(ret) {
-			err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		cParams, err := stmt.numParams()
+		if err != nil {
 			return err
 		}
-		for i := 0; i < int(cParams); i++ {
-			stmt.BindParam(i+1, params[i])
+		if len(params) != cParams {
+			return &ODBCError{SQLState: "07002", ErrorMessage: fmt.Sprintf("%d arguments for %d parameters", len(params), cParams)}
+		}
+		for i := 0; i < cParams; i++ {
+			if err := stmt.BindParam(i+1, params[i]); err != nil {
+				return err
+			}
 		}
 	}
-	ret := C.SQLExecute(C.SQLHSTMT(stmt.handle))
+	return nil
+}
+
+func (stmt *Statement) execute(ctx context.Context) *ODBCError {
+	stmt.warnings = nil
+	stmt.fields = nil
+	ret := poll(ctx, func() C.SQLRETURN {
+		return C.SQLExecute(C.SQLHSTMT(stmt.handle))
+	}, stmt.cancel)
+	stmt.checkInfo(ret)
 	if ret == C.SQL_NEED_DATA {
 		// TODO
 		//		send_data(stmt)
@@ -357,35 +542,20 @@ func (stmt *Statement) Execute2(params []driver.Value) *ODBCError {
 }
 
 func (stmt *Statement) Fetch() (bool, *ODBCError) {
-	ret := C.SQLFetch(C.SQLHSTMT(stmt.handle))
-	if ret == C.SQL_NO_DATA {
-		return false, nil
-	}
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
-		return false, err
-	}
-	return true, nil
+	return stmt.FetchContext(context.Background())
 }
 
 type Row struct {
 	Data []interface{}
+
+	fields []Field
 }
 
-// Get(Columnindex)
-// TODO Get(ColumnName)
+// Get returns the value of a column, given by index or by name (see
+// Row.Index), or nil if there is no such column.
 func (r *Row) Get(a interface{}) interface{} {
-	value := reflect.ValueOf(a)
-	switch f := value; f.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return r.Data[f.Int()]
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return r.Data[f.Uint()]
-		//	case *reflect.StringValue:
-		//		i := r.Meta[f.Get()]
-		//		return r.Data[i]
-	}
-	return nil
+	v, _ := r.Value(a)
+	return v
 }
 
 func (r *Row) GetInt(a interface{}) (ret int64) {
@@ -424,16 +594,19 @@ func (r *Row) Length() int {
 	return len(r.Data)
 }
 
+// FetchAll fetches the remaining rows into memory. Use Rows to process
+// large results row by row.
 func (stmt *Statement) FetchAll() (rows []*Row, err *ODBCError) {
 	for {
 		row, err := stmt.FetchOne()
-		if err != nil || row == nil {
-			break
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			return rows, nil
 		}
 		rows = append(rows, row)
 	}
-
-	return rows, err
 }
 
 func (stmt *Statement) FetchOne() (*Row, *ODBCError) {
@@ -441,11 +614,22 @@ func (stmt *Statement) FetchOne() (*Row, *ODBCError) {
 	if !ok {
 		return nil, err
 	}
-	n, _ := stmt.NumFields()
-	row := new(Row)
+	return stmt.currentRow()
+}
+
+// currentRow reads the columns of the row the cursor is on.
+func (stmt *Statement) currentRow() (*Row, *ODBCError) {
+	n, err := stmt.NumFields()
+	if err != nil {
+		return nil, err
+	}
+	row := &Row{fields: stmt.fields}
 	row.Data = make([]interface{}, n)
 	for i := 0; i < n; i++ {
-		v, _, _, _ := stmt.GetField(i)
+		v, _, _, err := stmt.GetField(i)
+		if err != nil {
+			return nil, err
+		}
 		row.Data[i] = v
 	}
 	return row, nil
@@ -458,200 +642,228 @@ func (stmt *Statement) FetchOne2(row []driver.Value) (eof bool, err *ODBCError)
 	} else if err != nil {
 		return false, err
 	}
-	n, _ := stmt.NumFields()
+	n, err := stmt.NumFields()
+	if err != nil {
+		return false, err
+	}
 	for i := 0; i < n; i++ {
-		v, _, _, _ := stmt.GetField(i)
+		v, _, _, err := stmt.GetField(i)
+		if err != nil {
+			return false, err
+		}
 		row[i] = v
 	}
 	return false, nil
 }
 
-func (stmt *Statement) GetField(field_index int) (v interface{}, ftype int, flen int, err *ODBCError) {
-	var field_type C.int
-	var field_len C.SQLLEN
-	var ll C.SQLSMALLINT
-	ret := C._SQLColAttribute(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_DESC_CONCISE_TYPE, C.SQLPOINTER(unsafe.Pointer(uintptr(0))), C.SQLSMALLINT(0), &ll, unsafe.Pointer(&field_type))
-	if !Success(ret) {
-		// TODO return err
+// getDataBuf receives the fixed-size values and the length indicator of
+// SQLGetData. It is kept in C memory for the life of the statement.
+type getDataBuf struct {
+	ind   C.SQLLEN
+	value C.TIMESTAMP_STRUCT // the largest fixed-size value
+}
+
+// getDataBuffer returns the getDataBuf of stmt, allocating it on first
+// use.
+func (stmt *Statement) getDataBuffer() *getDataBuf {
+	if stmt.getData == nil {
+		stmt.getData = (*getDataBuf)(cmalloc(unsafe.Sizeof(getDataBuf{})))
 	}
-	ret = C._SQLColAttribute(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_DESC_LENGTH, C.SQLPOINTER(unsafe.Pointer(uintptr(0))), C.SQLSMALLINT(0), &ll, unsafe.Pointer(&field_len))
-	if !Success(ret) {
-		// TODO return err
+	return stmt.getData
+}
+
+// getVarData reads column field_index as cType into a C buffer of n
+// bytes and returns a copy of the buffer.
+func (stmt *Statement) getVarData(field_index int, cType C.SQLSMALLINT, n int, fl *C.SQLLEN) ([]byte, C.SQLRETURN) {
+	value := cmalloc(uintptr(n))
+	defer C.free(value)
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), cType, C.SQLPOINTER(value), C.SQLLEN(n), fl)
+	})
+	return C.GoBytes(value, C.int(n)), ret
+}
+
+// getWideData reads a character column as SQL_C_WCHAR.
+func (stmt *Statement) getWideData(field_index int, field_len C.SQLLEN, fl *C.SQLLEN) (interface{}, C.SQLRETURN) {
+	value, ret := stmt.getVarData(field_index, C.SQL_C_WCHAR, (int(field_len)+8)*wcharSize, fl)
+	if *fl == C.SQL_NULL_DATA {
+		return nil, ret
 	}
-	var fl C.SQLLEN = C.SQLLEN(field_len)
-	switch int(field_type) {
+	return wideToString(value), ret
+}
+
+func (stmt *Statement) GetField(field_index int) (v interface{}, ftype int, flen int, err *ODBCError) {
+	fields, err := stmt.Columns()
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	if field_index < 0 || field_index >= len(fields) {
+		return nil, -1, -1, &ODBCError{SQLState: "07009", ErrorMessage: "invalid descriptor index"}
+	}
+	field_type := fields[field_index].Type
+	field_len := fields[field_index].length
+	var ret C.SQLRETURN
+	buf := stmt.getDataBuffer()
+	fl := &buf.ind
+	*fl = field_len
+	value := C.SQLPOINTER(unsafe.Pointer(&buf.value))
+	getData := func(cType C.SQLSMALLINT, n C.SQLLEN) C.SQLRETURN {
+		return stmt.wait(func() C.SQLRETURN {
+			return C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), cType, value, n, fl)
+		})
+	}
+	switch field_type {
 	case C.SQL_BIT:
-		var value C.BYTE
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_BIT, C.SQLPOINTER(unsafe.Pointer(&value)), 0, &fl)
-		if fl == -1 {
+		ret = getData(C.SQL_C_BIT, 0)
+		if *fl == -1 {
 			v = nil
 		} else {
-			v = byte(value)
+			v = byte(*(*C.BYTE)(value))
 		}
 	case C.SQL_INTEGER, C.SQL_SMALLINT, C.SQL_TINYINT:
-		var value C.long
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_LONG, C.SQLPOINTER(unsafe.Pointer(&value)), 0, &fl)
-		if fl == -1 {
+		ret = getData(C.SQL_C_LONG, 0)
+		if *fl == -1 {
 			v = nil
 		} else {
-			v = int(value)
+			v = int(*(*C.SQLINTEGER)(value))
 		}
 	case C.SQL_BIGINT:
-		var value C.longlong
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_SBIGINT, C.SQLPOINTER(unsafe.Pointer(&value)), 0, &fl)
-		if fl == -1 {
+		ret = getData(C.SQL_C_SBIGINT, 0)
+		if *fl == -1 {
 			v = nil
 		} else {
-			v = int64(value)
+			v = int64(*(*C.SQLBIGINT)(value))
 		}
 	case C.SQL_FLOAT, C.SQL_REAL, C.SQL_DOUBLE:
-		var value C.double
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_DOUBLE, C.SQLPOINTER(unsafe.Pointer(&value)), 0, &fl)
-		if fl == -1 {
+		ret = getData(C.SQL_C_DOUBLE, 0)
+		if *fl == -1 {
+			v = nil
+		} else {
+			v = float64(*(*C.SQLDOUBLE)(value))
+		}
+	case C.SQL_CHAR, C.SQL_VARCHAR, C.SQL_LONGVARCHAR:
+		if stmt.enc == nil {
+			v, ret = stmt.getWideData(field_index, field_len, fl)
+			break
+		}
+		// Multibyte client encodings use up to 4 bytes per character.
+		var b []byte
+		b, ret = stmt.getVarData(field_index, C.SQL_C_CHAR, 4*int(field_len)+8, fl)
+		if *fl == -1 {
 			v = nil
+		} else if s, err := decode(stmt.enc, b); err != nil {
+			return nil, field_type, int(*fl), err
 		} else {
-			v = float64(value)
+			v = s
 		}
-	case C.SQL_CHAR, C.SQL_VARCHAR, C.SQL_LONGVARCHAR, C.SQL_WCHAR, C.SQL_WVARCHAR, C.SQL_WLONGVARCHAR:
-		value := make([]uint16, int(field_len)+8)
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_WCHAR, C.SQLPOINTER(unsafe.Pointer(&value[0])), field_len+4, &fl)
-		s := UTF16ToString(value)
-		v = s
+	case C.SQL_WCHAR, C.SQL_WVARCHAR, C.SQL_WLONGVARCHAR:
+		v, ret = stmt.getWideData(field_index, field_len, fl)
 	case C.SQL_TYPE_TIMESTAMP, C.SQL_TYPE_DATE, C.SQL_TYPE_TIME, C.SQL_DATETIME:
-		var value C.TIMESTAMP_STRUCT
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_TYPE_TIMESTAMP, C.SQLPOINTER(unsafe.Pointer(&value)), C.SQLLEN(unsafe.Sizeof(value)), &fl)
-		if fl == -1 {
+		ret = getData(C.SQL_C_TYPE_TIMESTAMP, C.SQLLEN(unsafe.Sizeof(buf.value)))
+		if *fl == -1 {
 			v = nil
 		} else {
-			v = time.Date(int(value.year), time.Month(value.month), int(value.day), int(value.hour), int(value.minute), int(value.second), int(value.fraction), time.UTC)
+			ts := buf.value
+			v = time.Date(int(ts.year), time.Month(ts.month), int(ts.day), int(ts.hour), int(ts.minute), int(ts.second), int(ts.fraction), time.UTC)
 		}
 	case C.SQL_BINARY, C.SQL_VARBINARY, C.SQL_LONGVARBINARY:
-		var vv int
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_BINARY, C.SQLPOINTER(unsafe.Pointer(&vv)), 0, &fl)
-		if fl == -1 {
+		// A zero-length read returns the length of the value.
+		ret = getData(C.SQL_C_BINARY, 0)
+		if *fl == -1 {
 			v = nil
+		} else if *fl == 0 {
+			v = []byte{}
 		} else {
-			value := make([]byte, fl)
-			ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_BINARY, C.SQLPOINTER(unsafe.Pointer(&value[0])), C.SQLLEN(fl), &fl)
-			v = value
+			v, ret = stmt.getVarData(field_index, C.SQL_C_BINARY, int(*fl), fl)
 		}
 	default:
-		value := make([]byte, field_len)
-		ret = C.SQLGetData(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(field_index+1), C.SQL_C_BINARY, C.SQLPOINTER(unsafe.Pointer(&value[0])), field_len, &fl)
-		v = value
+		var b []byte
+		b, ret = stmt.getVarData(field_index, C.SQL_C_BINARY, int(field_len), fl)
+		if *fl == C.SQL_NULL_DATA {
+			v = nil
+		} else {
+			v = b
+		}
 	}
 	if !Success(ret) {
 		err = FormatError(C.SQL_HANDLE_STMT, stmt.handle)
 	}
-	return v, int(field_type), int(fl), err
+	return v, field_type, int(*fl), err
 }
 
 func (stmt *Statement) NumFields() (int, *ODBCError) {
-	var NOC C.SQLSMALLINT
-	ret := C.SQLNumResultCols(C.SQLHSTMT(stmt.handle), &NOC)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+	fields, err := stmt.Columns()
+	if err != nil {
 		return -1, err
 	}
-	return int(NOC), nil
+	return len(fields), nil
 }
 
 func (stmt *Statement) GetParamType(index int) (int, int, int, int, *ODBCError) {
-	var data_type, dec_ptr, null_ptr C.SQLSMALLINT
-	var size_ptr C.SQLULEN
-	ret := C.SQLDescribeParam(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(index), &data_type, &size_ptr, &dec_ptr, &null_ptr)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+	d, err := stmt.describeParamAt(index)
+	if err != nil {
 		return -1, -1, -1, -1, err
 	}
-	return int(data_type), int(size_ptr), int(dec_ptr), int(null_ptr), nil
+	return int(d.sqlType), int(d.size), int(d.digits), int(d.nullable), nil
 }
 
+// BindParam binds param to the parameter marker at index, starting at
+// 1. The value is copied into a buffer in C memory owned by stmt, which
+// stays valid until the parameter is bound again or stmt is closed.
 func (stmt *Statement) BindParam(index int, param interface{}) *ODBCError {
-	var ValueType C.SQLSMALLINT
-	var ParameterType C.SQLSMALLINT
-	var ColumnSize C.SQLULEN
-	var DecimalDigits C.SQLSMALLINT
-	var ParameterValuePtr C.SQLPOINTER
-	var BufferLength C.SQLLEN
-	var StrLen_or_IndPt C.SQLLEN
-	v := reflect.ValueOf(param)
-	if param == nil {
-		ft, _, _, _, err := stmt.GetParamType(index)
-		if err != nil {
-			return err
-		}
-		ParameterType = C.SQLSMALLINT(ft)
-		if ParameterType == C.SQL_UNKNOWN_TYPE {
-			ParameterType = C.SQL_VARCHAR
+	cv, err := newCValue(param, stmt.enc, stmt.wideStrings())
+	if err != nil {
+		return err
+	}
+	if cv.sqlType == C.SQL_WVARCHAR && stmt.conn != nil && int(cv.size) > stmt.conn.wvarcharMax() {
+		cv.sqlType = C.SQL_WLONGVARCHAR
+	}
+	if *cv.ind == C.SQL_NULL_DATA {
+		// NULL takes the type of the parameter, or stays SQL_VARCHAR
+		// if the driver cannot describe it.
+		if d := stmt.describeParam(index); d != nil && d.sqlType != C.SQL_UNKNOWN_TYPE {
+			cv.sqlType = d.sqlType
 		}
-		ValueType = C.SQL_C_DEFAULT
-		StrLen_or_IndPt = C.SQL_NULL_DATA
-		ColumnSize = 1
-	} else {
-		switch v.Kind() {
-		case reflect.Bool:
-			ParameterType = C.SQL_BIT
-			ValueType = C.SQL_C_BIT
-			var b [1]byte
-			if v.Bool() {
-				b[0] = 1
-			} else {
-				b[0] = 0
-			}
-			ParameterValuePtr = C.SQLPOINTER(unsafe.Pointer(&b[0]))
-			BufferLength = 1
-			StrLen_or_IndPt = 0
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			switch v.Type().Kind() {
-			case reflect.Int:
-			case reflect.Int8, reflect.Int16, reflect.Int32:
-				ParameterType = C.SQL_INTEGER
-				ValueType = C.SQL_C_LONG
-				var l C.long = C.long(v.Int())
-				ParameterValuePtr = C.SQLPOINTER(unsafe.Pointer(&l))
-				BufferLength = 4
-				StrLen_or_IndPt = 0
-			case reflect.Int64:
-				ParameterType = C.SQL_BIGINT
-				ValueType = C.SQL_C_SBIGINT
-				var ll C.longlong = C.longlong(v.Int())
-				ParameterValuePtr = C.SQLPOINTER(unsafe.Pointer(&ll))
-				BufferLength = 8
-				StrLen_or_IndPt = 0
-			}
-		case reflect.Float32, reflect.Float64:
-			ParameterType = C.SQL_DOUBLE
-			ValueType = C.SQL_C_DOUBLE
-			var d C.double = C.double(v.Float())
-			ParameterValuePtr = C.SQLPOINTER(unsafe.Pointer(&d))
-			BufferLength = 8
-			StrLen_or_IndPt = 0
-		case reflect.Complex64, reflect.Complex128:
-		case reflect.String:
-			var slen C.SQLUINTEGER = C.SQLUINTEGER(len(v.String()))
-			ParameterType = C.SQL_VARCHAR
-			ValueType = C.SQL_C_CHAR
-			s := []byte(v.String())
-			ParameterValuePtr = C.SQLPOINTER(unsafe.Pointer(&s[0]))
-			ColumnSize = C.SQLULEN(slen)
-			BufferLength = C.SQLLEN(slen + 1)
-			StrLen_or_IndPt = C.SQLLEN(slen)
-		default:
-			fmt.Println("Not support type", v)
+		cv.cType = C.SQL_C_DEFAULT
+	} else if stmt.describeParams {
+		if d := stmt.describeParam(index); d != nil {
+			cv.describe(d)
 		}
 	}
-	ret := C.SQLBindParameter(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(index), C.SQL_PARAM_INPUT, ValueType, ParameterType, ColumnSize, DecimalDigits, ParameterValuePtr, BufferLength, &StrLen_or_IndPt)
+	ret := C.SQLBindParameter(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(index), C.SQL_PARAM_INPUT, cv.cType, cv.sqlType, cv.size, cv.digits, C.SQLPOINTER(cv.buf), cv.buflen, cv.ind)
 	if !Success(ret) {
 		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		cv.free()
 		return err
 	}
-
+	if stmt.params == nil {
+		stmt.params = make(map[int]*cValue)
+	}
+	if old := stmt.params[index]; old != nil {
+		old.free()
+	}
+	stmt.params[index] = cv
 	return nil
 }
 
+// freeParams unbinds the parameters and frees their buffers.
+func (stmt *Statement) freeParams() {
+	if len(stmt.params) == 0 {
+		return
+	}
+	C.SQLFreeStmt(C.SQLHSTMT(stmt.handle), C.SQL_RESET_PARAMS)
+	for _, cv := range stmt.params {
+		cv.free()
+	}
+	stmt.params = nil
+}
+
 func (stmt *Statement) NextResult() bool {
-	ret := C.SQLMoreResults(C.SQLHSTMT(stmt.handle))
+	stmt.fields = nil
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLMoreResults(C.SQLHSTMT(stmt.handle))
+	})
+	stmt.checkInfo(ret)
 	if ret == C.SQL_NO_DATA {
 		return false
 	}
@@ -673,83 +885,107 @@ func (stmt *Statement) HasRows() bool {
 	return n > 0
 }
 
+// Field describes a column of a result set, see Statement.Columns.
 type Field struct {
 	Name          string
-	Type          int
+	Type          int // concise SQL type, e.g. SQL_TYPE_TIMESTAMP
 	Size          int
 	DecimalDigits int
 	Nullable      int
+
+	// Extended attributes from SQLColAttribute. They are left empty when
+	// the driver does not report them.
+	TypeName      string // data source type name, e.g. "nvarchar"
+	Label         string
+	BaseTable     string
+	BaseColumn    string
+	AutoIncrement bool
+	Unsigned      bool
+	CaseSensitive bool
+
+	length C.SQLLEN // SQL_DESC_LENGTH, sizes GetField buffers
 }
 
+// FieldMetadata returns the description of column col, starting at 1.
 func (stmt *Statement) FieldMetadata(col int) (*Field, *ODBCError) {
-	var BufferLength C.SQLSMALLINT = INFO_BUFFER_LEN
-	var NameLength C.SQLSMALLINT
-	var DataType C.SQLSMALLINT
-	var ColumnSize C.SQLULEN
-	var DecimalDigits C.SQLSMALLINT
-	var Nullable C.SQLSMALLINT
-	ColumnName := make([]byte, INFO_BUFFER_LEN)
-	ret := C.SQLDescribeCol(C.SQLHSTMT(stmt.handle),
-		C.SQLUSMALLINT(col),
-		(*C.SQLCHAR)(unsafe.Pointer(&ColumnName[0])),
-		BufferLength,
-		&NameLength,
-		&DataType,
-		&ColumnSize,
-		&DecimalDigits,
-		&Nullable)
-	if !Success(ret) {
-		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+	fields, err := stmt.Columns()
+	if err != nil {
 		return nil, err
 	}
-	field := &Field{string(ColumnName[0:NameLength]), int(DataType), int(ColumnSize), int(DecimalDigits), int(Nullable)}
-	return field, nil
+	if col < 1 || col > len(fields) {
+		return nil, &ODBCError{SQLState: "07009", ErrorMessage: "invalid descriptor index"}
+	}
+	field := fields[col-1]
+	return &field, nil
 }
 
 func (stmt *Statement) free() {
+	stmt.freeParams()
 	C.SQLFreeHandle(C.SQL_HANDLE_STMT, stmt.handle)
+	if stmt.bookmark != nil {
+		C.free(stmt.bookmark)
+		stmt.bookmark = nil
+	}
+	if stmt.getData != nil {
+		C.free(unsafe.Pointer(stmt.getData))
+		stmt.getData = nil
+	}
 }
 
 func (stmt *Statement) Close() {
 	stmt.free()
 }
 
+// wstr returns b, as encoded by stringToWide or allocated by wideBuffer,
+// as a SQLWCHAR pointer.
+func wstr(b []byte) *C.SQLWCHAR {
+	return (*C.SQLWCHAR)(unsafe.Pointer(&b[0]))
+}
+
 func Success(ret C.SQLRETURN) bool {
 	return int(ret) == C.SQL_SUCCESS || int(ret) == C.SQL_SUCCESS_WITH_INFO
 }
 
 func FormatError(ht C.SQLSMALLINT, h C.SQLHANDLE) (err *ODBCError) {
-	sqlState := make([]uint16, 6)
+	sqlState := wideBuffer(6)
 	var nativeError C.SQLINTEGER
-	messageText := make([]uint16, C.SQL_MAX_MESSAGE_LENGTH)
+	messageText := wideBuffer(C.SQL_MAX_MESSAGE_LENGTH)
 	var textLength C.SQLSMALLINT
 	err = &ODBCError{}
+	if ht == C.SQL_HANDLE_STMT {
+		n, _ := diagFieldInt(ht, h, 0, C.SQL_DIAG_ROW_COUNT)
+		err.RowCount = int(n)
+	}
+	err.DynamicFunction = diagFieldString(ht, h, 0, C.SQL_DIAG_DYNAMIC_FUNCTION)
 	i := 0
 	for {
 		i++
 		ret := C.SQLGetDiagRecW(C.SQLSMALLINT(ht),
 			h,
 			C.SQLSMALLINT(i),
-			(*C.SQLWCHAR)(unsafe.Pointer(&sqlState[0])),
+			wstr(sqlState),
 			&nativeError,
-			(*C.SQLWCHAR)(unsafe.Pointer(&messageText[0])),
+			wstr(messageText),
 			C.SQL_MAX_MESSAGE_LENGTH,
 			&textLength)
 		if ret == C.SQL_INVALID_HANDLE || ret == C.SQL_NO_DATA {
 			break
 		}
+		rec := newDiagRecord(ht, h, i)
+		rec.SQLState = wideToString(sqlState)
+		rec.NativeError = int(nativeError)
+		rec.Message = wideToString(messageText)
+		err.Records = append(err.Records, rec)
 		if i == 1 { // first error message save the SQLSTATE.
-			err.SQLState = UTF16ToString(sqlState)
-			err.NativeError = int(nativeError)
+			err.SQLState = rec.SQLState
+			err.NativeError = rec.NativeError
 		}
-		err.ErrorMessage += UTF16ToString(messageText)
+		err.ErrorMessage += rec.Message
 	}
 
 	return err
 }
 
 func init() {
-	if err := initEnv(); err != nil {
-		panic("odbc init env error!" + err.String())
-	}
+	wcharSize = int(C.sizeof_SQLWCHAR)
 }
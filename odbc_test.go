@@ -0,0 +1,331 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+// Tests that need a data source connect to the connection string in
+// ODBC_TEST_DSN and are skipped without it. They are written for the
+// unixODBC SQLite driver, e.g.
+//
+//	ODBC_TEST_DSN="DRIVER=SQLite3;DATABASE=/tmp/odbc_test.db"
+//
+// Run them with the cgo pointer checks and the race detector on:
+//
+//	GOEXPERIMENT=cgocheck2 go test -race ./...
+//
+// or with GODEBUG=cgocheck=2 before Go 1.21.
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func testConn(t *testing.T) *Connection {
+	t.Helper()
+	dsn := os.Getenv("ODBC_TEST_DSN")
+	if dsn == "" {
+		t.Skip("ODBC_TEST_DSN not set")
+	}
+	conn, err := Connect(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func testExec(t *testing.T, conn *Connection, sql string, args ...interface{}) {
+	t.Helper()
+	stmt, err := conn.Prepare(sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	defer stmt.Close()
+	if err := stmt.Execute(args...); err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+}
+
+// testTable creates table name with the given columns, dropping any
+// table left over from an earlier run, and drops it after the test.
+func testTable(t *testing.T, conn *Connection, name, columns string) {
+	t.Helper()
+	if stmt, err := conn.ExecDirect("drop table " + name); err == nil {
+		stmt.Close()
+	}
+	testExec(t, conn, fmt.Sprintf("create table %s (%s)", name, columns))
+	t.Cleanup(func() {
+		if stmt, err := conn.ExecDirect("drop table " + name); err == nil {
+			stmt.Close()
+		}
+	})
+}
+
+func TestNewCValue(t *testing.T) {
+	tests := []struct {
+		param interface{}
+		ind   int64
+		data  []byte
+	}{
+		{nil, -1, nil},
+		{(*int)(nil), -1, nil},
+		{[]byte("abc"), 3, []byte("abc")},
+		{[]byte{}, 0, nil},
+		{int64(0x0102030405060708), 0, nil},
+		{"abc", 3, []byte("abc\x00")},
+		{"", 0, []byte{0}},
+	}
+	for _, tt := range tests {
+		cv, err := newCValue(tt.param, nil, false)
+		if err != nil {
+			t.Fatalf("%#v: %v", tt.param, err)
+		}
+		if cv.buf == nil || cv.buflen < 1 || cv.ind == nil {
+			t.Fatalf("%#v: no buffer", tt.param)
+		}
+		if got := int64(*cv.ind); got != tt.ind {
+			t.Errorf("%#v: indicator %d, want %d", tt.param, got, tt.ind)
+		}
+		if tt.data != nil {
+			got := unsafe.Slice((*byte)(cv.buf), int(cv.buflen))
+			if string(got) != string(tt.data) {
+				t.Errorf("%#v: buffer %q, want %q", tt.param, got, tt.data)
+			}
+		}
+		cv.free()
+		if cv.buf != nil || cv.ind != nil {
+			t.Errorf("%#v: buffers not released", tt.param)
+		}
+	}
+}
+
+func TestNewCValueUnsupported(t *testing.T) {
+	for _, param := range []interface{}{struct{}{}, []int{1}, uint64(1 << 63)} {
+		if cv, err := newCValue(param, nil, false); err == nil {
+			cv.free()
+			t.Errorf("%#v: no error", param)
+		}
+	}
+}
+
+// TestBindParamGC binds values that become garbage right away and
+// collects them before the statement is executed, so that any buffer the
+// driver reads at execution time which is not in C memory shows.
+func TestBindParamGC(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_bind", "id integer, s varchar(64)")
+	stmt, err := conn.Prepare("insert into odbc_bind (id, s) values (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	const n = 100
+	for i := 0; i < n; i++ {
+		if err := stmt.BindParam(1, i); err != nil {
+			t.Fatal(err)
+		}
+		if err := stmt.BindParam(2, fmt.Sprint("value ", i)); err != nil {
+			t.Fatal(err)
+		}
+		runtime.GC()
+		if err := stmt.Execute(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sel, err := conn.Prepare("select id, s from odbc_bind order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sel.Close()
+	if err := sel.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := sel.FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != n {
+		t.Fatalf("%d rows, want %d", len(rows), n)
+	}
+	for i, row := range rows {
+		var id int
+		var s string
+		if err := row.Scan(&id, &s); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprint("value ", i); id != i || s != want {
+			t.Errorf("row %d is (%d, %q), want (%d, %q)", i, id, s, i, want)
+		}
+	}
+}
+
+func TestBindParamClose(t *testing.T) {
+	conn := testConn(t)
+	stmt, err := conn.Prepare("select ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stmt.BindParam(1, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stmt.BindParam(1, "abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if len(stmt.params) != 1 {
+		t.Fatalf("%d parameter buffers, want 1", len(stmt.params))
+	}
+	stmt.Close()
+	if stmt.params != nil {
+		t.Error("parameter buffers not released by Close")
+	}
+}
+
+// TestBindParamConcurrent runs statements on several connections at
+// once for the race detector.
+func TestBindParamConcurrent(t *testing.T) {
+	testConn(t)
+	dsn := os.Getenv("ODBC_TEST_DSN")
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			conn, err := Connect(dsn)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			stmt, err := conn.Prepare("select ?")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer stmt.Close()
+			for i := 0; i < 50; i++ {
+				want := fmt.Sprintf("%d-%d", g, i)
+				if err := stmt.Execute(want); err != nil {
+					t.Error(err)
+					return
+				}
+				row, err := stmt.FetchOne()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var got string
+				if row == nil {
+					t.Error("no row")
+				} else if err := row.Scan(&got); err != nil {
+					t.Error(err)
+				} else if got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+				if err := stmt.Rows().Close(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestConnectionClose(t *testing.T) {
+	env, err := NewEnvironment(EnvConfig{})
+	if err != nil {
+		t.Skip("no driver manager: ", err)
+	}
+	conn, err := env.NewConnection()
+	if err != nil {
+		env.Close()
+		t.Skip("no connection handle: ", err)
+	}
+	if err := env.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if env.handle == nil {
+		t.Fatal("environment freed with a connection open")
+	}
+	for i := 0; i < 2; i++ {
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if env.conns != 0 {
+		t.Errorf("environment has %d connections after Close, want 0", env.conns)
+	}
+	if env.handle != nil {
+		t.Error("environment not freed after its last connection was closed")
+	}
+}
+
+func TestExecuteArgumentCount(t *testing.T) {
+	conn := testConn(t)
+	stmt, err := conn.Prepare("select ?, ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, args := range [][]interface{}{{}, {1}, {1, 2, 3}} {
+		if err := stmt.Execute(args...); err == nil || err.SQLState != "07002" {
+			t.Errorf("%d arguments for 2 parameters: %v, want 07002", len(args), err)
+		}
+	}
+	if err := stmt.Execute(1, 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBindNull binds NULL in its three forms, with the parameters
+// described by the driver and as if it could not describe them.
+func TestBindNull(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_bind_null", "id integer, n integer, s varchar(20)")
+	stmt, err := conn.Prepare("insert into odbc_bind_null values (?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if err := stmt.Execute(1, nil, sql.NullString{}); err != nil {
+		t.Fatal(err)
+	}
+	// No SQLDescribeParam (IM001, HYC00) leaves an empty cache.
+	stmt.paramDescs = make([]*paramDesc, 3)
+	if err := stmt.Execute(2, (*int64)(nil), nil); err != nil {
+		t.Fatal("without parameter types: ", err)
+	}
+	if n := testCount(t, conn, "odbc_bind_null where n is null and s is null"); n != 2 {
+		t.Errorf("%d NULL rows, want 2", n)
+	}
+}
+
+// TestTypeInfoLazy checks that the WVARCHAR limit is only read for the
+// first string parameter, not when connecting.
+func TestTypeInfoLazy(t *testing.T) {
+	conn := testConn(t)
+	if conn.maxWVarchar != 0 {
+		t.Fatal("type information read when connecting")
+	}
+	testTable(t, conn, "odbc_type_info", "id integer")
+	testExec(t, conn, "insert into odbc_type_info values (?)", 1)
+	if conn.maxWVarchar != 0 {
+		t.Error("type information read for an integer parameter")
+	}
+	testExec(t, conn, "insert into odbc_type_info values (?)", "2")
+	if conn.maxWVarchar == 0 {
+		t.Log("driver reports no WVARCHAR size, using ", DEFAULT_WVARCHAR_MAX)
+	}
+	size := conn.maxWVarchar
+	testExec(t, conn, "insert into odbc_type_info values (?)", "3")
+	if conn.maxWVarchar != size {
+		t.Errorf("WVARCHAR size changed from %d to %d", size, conn.maxWVarchar)
+	}
+}
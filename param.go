@@ -0,0 +1,220 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#include <stdlib.h>
+
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// paramDesc is a parameter description returned by SQLDescribeParam.
+type paramDesc struct {
+	sqlType  C.SQLSMALLINT
+	size     C.SQLULEN
+	digits   C.SQLSMALLINT
+	nullable C.SQLSMALLINT
+}
+
+// SetDescribeParams makes the connection's statements bind parameters
+// with the SQL type, column size and decimal digits the driver reports
+// for them, see Statement.SetDescribeParams.
+func (conn *Connection) SetDescribeParams(on bool) {
+	conn.describeParams = on
+}
+
+// SetDescribeParams makes BindParam ask the driver for the type of each
+// parameter of the prepared statement (SQLDescribeParam) and bind the
+// value as that type, e.g. a string as NVARCHAR(50) for an NVARCHAR(50)
+// column, rather than as a type derived from the Go value. Descriptions
+// are cached until the next Prepare. When the driver cannot describe
+// parameters the Go-derived types are used.
+func (stmt *Statement) SetDescribeParams(on bool) {
+	stmt.describeParams = on
+}
+
+// describeParam returns the cached description of parameter index,
+// describing all parameters on first use. It returns nil if the driver
+// cannot describe the parameter.
+func (stmt *Statement) describeParam(index int) *paramDesc {
+	if stmt.paramDescs == nil {
+		n, err := stmt.numParams()
+		if err != nil {
+			n = 0
+		}
+		stmt.paramDescs = make([]*paramDesc, n)
+		for i := range stmt.paramDescs {
+			d, err := stmt.describeParamAt(i + 1)
+			if err != nil {
+				// Not supported by the driver (IM001, HYC00) or not
+				// for this statement; the other parameters would fail
+				// alike.
+				break
+			}
+			stmt.paramDescs[i] = d
+		}
+	}
+	if index < 1 || index > len(stmt.paramDescs) {
+		return nil
+	}
+	return stmt.paramDescs[index-1]
+}
+
+// describeParamAt calls SQLDescribeParam for parameter index.
+func (stmt *Statement) describeParamAt(index int) (*paramDesc, *ODBCError) {
+	d := (*paramDesc)(cmalloc(unsafe.Sizeof(paramDesc{})))
+	defer C.free(unsafe.Pointer(d))
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLDescribeParam(C.SQLHSTMT(stmt.handle), C.SQLUSMALLINT(index), &d.sqlType, &d.size, &d.digits, &d.nullable)
+	})
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, stmt.handle)
+		return nil, err
+	}
+	desc := *d
+	return &desc, nil
+}
+
+// describe sets the SQL type of cv from the parameter description d.
+// Character and binary sizes are not made smaller than the value.
+func (cv *cValue) describe(d *paramDesc) {
+	if d.sqlType == C.SQL_UNKNOWN_TYPE {
+		return
+	}
+	cv.sqlType = d.sqlType
+	switch d.sqlType {
+	case C.SQL_CHAR, C.SQL_VARCHAR, C.SQL_LONGVARCHAR,
+		C.SQL_WCHAR, C.SQL_WVARCHAR, C.SQL_WLONGVARCHAR,
+		C.SQL_BINARY, C.SQL_VARBINARY, C.SQL_LONGVARBINARY:
+		if d.size > cv.size {
+			cv.size = d.size
+		}
+	default:
+		cv.size = d.size
+	}
+	cv.digits = d.digits
+}
+
+// SetNarrowStrings makes the connection's statements bind string
+// parameters as SQL_C_CHAR / SQL_VARCHAR in UTF-8, see
+// Statement.SetNarrowStrings.
+func (conn *Connection) SetNarrowStrings(on bool) {
+	conn.narrowStrings = on
+}
+
+// SetNarrowStrings makes BindParam send strings as UTF-8 SQL_C_CHAR /
+// SQL_VARCHAR instead of SQL_C_WCHAR / SQL_WVARCHAR, for drivers whose
+// Unicode support is lacking. In ANSI mode, see SetEncoding, strings are
+// always narrow.
+func (stmt *Statement) SetNarrowStrings(on bool) {
+	stmt.narrowStrings = on
+}
+
+func (stmt *Statement) wideStrings() bool {
+	return stmt.enc == nil && !stmt.narrowStrings
+}
+
+// DEFAULT_WVARCHAR_MAX is the largest SQL_WVARCHAR parameter, in
+// characters, when the driver does not report one.
+const DEFAULT_WVARCHAR_MAX = 4000
+
+// wvarcharMax returns the largest column size of SQL_WVARCHAR, read by
+// loadTypeInfo on the first call for the connection. Longer string
+// parameters are bound as SQL_WLONGVARCHAR.
+func (conn *Connection) wvarcharMax() int {
+	conn.typeInfo.Do(conn.loadTypeInfo)
+	if conn.maxWVarchar > 0 {
+		return conn.maxWVarchar
+	}
+	return DEFAULT_WVARCHAR_MAX
+}
+
+// loadTypeInfo reads the largest column size of SQL_WVARCHAR from
+// SQLGetTypeInfo. If the driver does not report it, DEFAULT_WVARCHAR_MAX
+// is used.
+func (conn *Connection) loadTypeInfo() {
+	stmt, err := conn.newStmt()
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	ret := stmt.wait(func() C.SQLRETURN {
+		return C.SQLGetTypeInfo(C.SQLHSTMT(stmt.handle), C.SQL_WVARCHAR)
+	})
+	if !Success(ret) {
+		return
+	}
+	ret = stmt.wait(func() C.SQLRETURN {
+		return C.SQLFetch(C.SQLHSTMT(stmt.handle))
+	})
+	if !Success(ret) {
+		return
+	}
+	size := (*C.SQLINTEGER)(cmalloc(unsafe.Sizeof(C.SQLINTEGER(0))))
+	defer C.free(unsafe.Pointer(size))
+	ind := (*C.SQLLEN)(cmalloc(unsafe.Sizeof(C.SQLLEN(0))))
+	defer C.free(unsafe.Pointer(ind))
+	// COLUMN_SIZE is the third column of the result.
+	ret = stmt.wait(func() C.SQLRETURN {
+		return C.SQLGetData(C.SQLHSTMT(stmt.handle), 3, C.SQL_C_SLONG, C.SQLPOINTER(unsafe.Pointer(size)), 0, ind)
+	})
+	if Success(ret) && *ind != C.SQL_NULL_DATA && *size > 0 {
+		conn.maxWVarchar = int(*size)
+	}
+}
+
+// ParamInfo describes a parameter marker of a prepared statement, see
+// Statement.Params.
+type ParamInfo struct {
+	Type          int // SQL type, e.g. SQL_WVARCHAR
+	Size          int
+	DecimalDigits int
+	Nullable      int // SQL_NO_NULLS, SQL_NULLABLE or SQL_NULLABLE_UNKNOWN
+
+	// From the implementation parameter descriptor, where the driver
+	// fills it in: the name of a procedure parameter and the data source
+	// type name.
+	Name     string
+	TypeName string
+}
+
+// Params describes the parameter markers of the prepared statement, in
+// order. It fails if the driver does not support SQLDescribeParam.
+func (stmt *Statement) Params() ([]ParamInfo, *ODBCError) {
+	n, err := stmt.numParams()
+	if err != nil {
+		return nil, err
+	}
+	ipd, _ := stmt.IPD()
+	params := make([]ParamInfo, n)
+	for i := range params {
+		d, err := stmt.describeParamAt(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		p := &params[i]
+		p.Type = int(d.sqlType)
+		p.Size = int(d.size)
+		p.DecimalDigits = int(d.digits)
+		p.Nullable = int(d.nullable)
+		if ipd != nil {
+			p.Name, _ = ipd.FieldString(i+1, DESC_NAME)
+			p.TypeName, _ = ipd.FieldString(i+1, DESC_TYPE_NAME)
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+
+// Connection pooling modes, see SetConnectionPooling.
+const (
+	CP_OFF            = C.SQL_CP_OFF
+	CP_ONE_PER_DRIVER = C.SQL_CP_ONE_PER_DRIVER
+	CP_ONE_PER_HENV   = C.SQL_CP_ONE_PER_HENV
+	CP_DRIVER_AWARE   = C.SQL_CP_DRIVER_AWARE
+)
+
+// Connection pool matching, see SetConnectionPooling.
+const (
+	CP_STRICT_MATCH  = C.SQL_CP_STRICT_MATCH
+	CP_RELAXED_MATCH = C.SQL_CP_RELAXED_MATCH
+)
+
+// SetConnectionPooling configures the driver manager's connection
+// pooling (SQL_ATTR_CONNECTION_POOLING) for the default environment to
+// one of the CP_* modes, and how pooled connections are matched
+// (SQL_ATTR_CP_MATCH). With pooling on, Connection.Close returns the
+// connection to the pool and Connect reuses a matching one.
+//
+// The setting applies when the default environment is allocated, so it
+// must be called before the first connection. Use EnvConfig for other
+// environments.
+func SetConnectionPooling(pooling int, match int) *ODBCError {
+	envMu.Lock()
+	cfg := defaultConfig
+	envMu.Unlock()
+	cfg.Pooling = pooling
+	cfg.PoolMatch = match
+	return ConfigureDefaultEnvironment(cfg)
+}
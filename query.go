@@ -0,0 +1,146 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package odbc
+
+import (
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+	"time"
+)
+
+// QueryAll executes query with args on conn and returns all rows as
+// values of T. A struct T, or pointer to one, receives the columns as
+// with FetchStruct; any other T receives the only column of each row as
+// with Row.Scan:
+//
+//	users, err := odbc.QueryAll[User](conn, "select * from users where age > ?", 30)
+//	names, err := odbc.QueryAll[string](conn, "select name from users")
+func QueryAll[T any](conn *Connection, query string, args ...any) ([]T, error) {
+	var all []T
+	for v, err := range Query[T](conn, query, args...) {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, v)
+	}
+	return all, nil
+}
+
+// QueryOne is like QueryAll, but returns only the first row, or
+// sql.ErrNoRows if there is none.
+func QueryOne[T any](conn *Connection, query string, args ...any) (T, error) {
+	for v, err := range Query[T](conn, query, args...) {
+		return v, err
+	}
+	var zero T
+	return zero, sql.ErrNoRows
+}
+
+// Query executes query with args on conn and iterates over the rows as
+// values of T, see QueryAll. The statement is closed when the loop ends:
+//
+//	for u, err := range odbc.Query[User](conn, "select * from users") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Query[T any](conn *Connection, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		stmt, err := conn.Prepare(query)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer stmt.Close()
+		if err := stmt.Execute(args...); err != nil {
+			yield(zero, err)
+			return
+		}
+		var plan [][]int
+		for {
+			row, err := stmt.FetchOne()
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if row == nil {
+				return
+			}
+			v, err := scanValue[T](row, &plan)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scanValue converts row to a T. plan caches the struct plan across the
+// rows of a result set.
+func scanValue[T any](row *Row, plan *[][]int) (T, *ODBCError) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	target := rv
+	if rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Struct {
+		target = reflect.New(rv.Type().Elem()).Elem()
+	}
+	if target.Kind() == reflect.Struct && target.Type() != timeType && !reflect.PointerTo(target.Type()).Implements(scannerType) {
+		if *plan == nil {
+			*plan = structPlan(target.Type(), row.fields)
+		}
+		if err := row.scanStruct(target, *plan); err != nil {
+			return v, err
+		}
+		if target != rv {
+			rv.Set(target.Addr())
+		}
+		return v, nil
+	}
+	if len(row.Data) != 1 {
+		return v, &ODBCError{SQLState: "07002", ErrorMessage: fmt.Sprintf("%d columns for a %T", len(row.Data), v)}
+	}
+	if err := convertAssign(&v, row.Data[0]); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// All returns r as an iterator for a range loop. The last pair carries
+// the error that ended the iteration, if any, and breaking out of the
+// loop closes r:
+//
+//	for row, err := range stmt.Rows().All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (r *Rows) All() iter.Seq2[*Row, error] {
+	return func(yield func(*Row, error) bool) {
+		defer r.Close()
+		for r.Next() {
+			if !yield(r.row, nil) {
+				return
+			}
+		}
+		if r.err != nil {
+			yield(nil, r.err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package odbc
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScanValue(t *testing.T) {
+	row := &Row{Data: []interface{}{int64(7), "bob"}, fields: testFields("id", "user_name")}
+	var plan [][]int
+	p, err := scanValue[*Person](row, &plan)
+	if err != nil || p == nil || p.ID != 7 || p.Name != "bob" {
+		t.Fatalf("*Person: %+v, %v", p, err)
+	}
+	if plan == nil {
+		t.Error("no plan kept for the next rows")
+	}
+	v, err := scanValue[Person](row, &plan)
+	if err != nil || v.ID != 7 || v.Name != "bob" {
+		t.Fatalf("Person: %+v, %v", v, err)
+	}
+	if _, err := scanValue[int](row, new([][]int)); err == nil || err.SQLState != "07002" {
+		t.Errorf("int from two columns: %v, want 07002", err)
+	}
+
+	// Scanners and time.Time are scalars, although structs.
+	null := &Row{Data: []interface{}{nil}, fields: testFields("x")}
+	ns, err := scanValue[sql.NullString](null, new([][]int))
+	if err != nil || ns.Valid {
+		t.Errorf("sql.NullString: %+v, %v", ns, err)
+	}
+	pi, err := scanValue[*int](null, new([][]int))
+	if err != nil || pi != nil {
+		t.Errorf("*int: %v, %v", pi, err)
+	}
+	if _, err := scanValue[int](null, new([][]int)); err == nil {
+		t.Error("no error for NULL into int")
+	}
+	now := time.Now()
+	at, err := scanValue[time.Time](&Row{Data: []interface{}{now}, fields: testFields("at")}, new([][]int))
+	if err != nil || !at.Equal(now) {
+		t.Errorf("time.Time: %v, %v", at, err)
+	}
+}
+
+func testPeople(t *testing.T) *Connection {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_people", "id integer, user_name varchar(20)")
+	for i, name := range []string{"ann", "bob", "cid"} {
+		testExec(t, conn, "insert into odbc_people values (?, ?)", i+1, name)
+	}
+	return conn
+}
+
+func TestQueryAll(t *testing.T) {
+	conn := testPeople(t)
+	people, err := QueryAll[Person](conn, "select id, user_name from odbc_people where id > ? order by id", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 || people[0].ID != 2 || people[0].Name != "bob" || people[1].Name != "cid" {
+		t.Errorf("QueryAll[Person] = %+v", people)
+	}
+	names, err := QueryAll[string](conn, "select user_name from odbc_people order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[0] != "ann" || names[2] != "cid" {
+		t.Errorf("QueryAll[string] = %q", names)
+	}
+	if _, err := QueryAll[string](conn, "select id, user_name from odbc_people"); err == nil {
+		t.Error("no error for a string from two columns")
+	}
+	if _, err := QueryAll[string](conn, "select nothing from odbc_people"); err == nil {
+		t.Error("no error for an invalid query")
+	}
+}
+
+func TestQueryOne(t *testing.T) {
+	conn := testPeople(t)
+	p, err := QueryOne[*Person](conn, "select id, user_name from odbc_people where id = ?", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.ID != 3 || p.Name != "cid" {
+		t.Errorf("QueryOne[*Person] = %+v", p)
+	}
+	n, err := QueryOne[int](conn, "select count(*) from odbc_people")
+	if err != nil || n != 3 {
+		t.Errorf("QueryOne[int] = %d, %v", n, err)
+	}
+	if _, err := QueryOne[Person](conn, "select id, user_name from odbc_people where id = ?", 4); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("no rows: %v, want sql.ErrNoRows", err)
+	}
+}
+
+// TestQueryBreak leaves a Query loop early: the statement must be
+// closed, so that the connection can run the next query.
+func TestQueryBreak(t *testing.T) {
+	conn := testPeople(t)
+	var seen []int64
+	for id, err := range Query[int64](conn, "select id from odbc_people order by id") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, id)
+		if len(seen) == 2 {
+			break
+		}
+	}
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 2 {
+		t.Errorf("iterated over %v", seen)
+	}
+	testExec(t, conn, "delete from odbc_people where id = ?", 1)
+	n, err := QueryOne[int](conn, "select count(*) from odbc_people")
+	if err != nil || n != 2 {
+		t.Errorf("after break: count = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns returns the names of the columns of the row.
+func (r *Row) Columns() []string {
+	names := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Index returns the index of column col, which is either an index or a
+// column name. Names match exactly or else case-insensitively; if
+// several columns have the name, the first one is used.
+func (r *Row) Index(col interface{}) (int, *ODBCError) {
+	switch c := col.(type) {
+	case string:
+		for i, f := range r.fields {
+			if f.Name == c {
+				return i, nil
+			}
+		}
+		for i, f := range r.fields {
+			if strings.EqualFold(f.Name, c) {
+				return i, nil
+			}
+		}
+		return -1, &ODBCError{SQLState: "07009", ErrorMessage: fmt.Sprintf("no column named %q", c)}
+	}
+	v := reflect.ValueOf(col)
+	i := -1
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n < int64(len(r.Data)) {
+			i = int(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n < uint64(len(r.Data)) {
+			i = int(n)
+		}
+	default:
+		return -1, &ODBCError{SQLState: "HY000", ErrorMessage: fmt.Sprintf("invalid column %v of type %T", col, col)}
+	}
+	if i < 0 {
+		return -1, &ODBCError{SQLState: "07009", ErrorMessage: fmt.Sprintf("column index %v out of range", col)}
+	}
+	return i, nil
+}
+
+// Value returns the value of column col, see Index.
+func (r *Row) Value(col interface{}) (interface{}, *ODBCError) {
+	i, err := r.Index(col)
+	if err != nil {
+		return nil, err
+	}
+	return r.Data[i], nil
+}
+
+// Int returns column col as an integer. Numbers and numeric text are
+// converted; NULL and other values are errors.
+func (r *Row) Int(col interface{}) (v int64, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+// Float returns column col as a float64, see Int.
+func (r *Row) Float(col interface{}) (v float64, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+// Text returns column col as a string. Numbers, times and byte slices
+// are formatted; NULL is an error.
+func (r *Row) Text(col interface{}) (v string, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+// Time returns column col as a time.Time; NULL is an error.
+func (r *Row) Time(col interface{}) (v time.Time, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+// Bytes returns column col as a byte slice; strings are converted and
+// NULL is nil.
+func (r *Row) Bytes(col interface{}) (v []byte, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+// Bool returns column col as a bool. Numbers are true when non-zero and
+// text is parsed by strconv.ParseBool; NULL is an error.
+func (r *Row) Bool(col interface{}) (v bool, err *ODBCError) {
+	err = r.scanColumn(col, &v)
+	return
+}
+
+func (r *Row) GetTime(a interface{}) time.Time {
+	v, _ := r.Time(a)
+	return v
+}
+
+func (r *Row) GetBytes(a interface{}) []byte {
+	v, _ := r.Bytes(a)
+	return v
+}
+
+func (r *Row) GetBool(a interface{}) bool {
+	v, _ := r.Bool(a)
+	return v
+}
+
+func (r *Row) scanColumn(col interface{}, dest interface{}) *ODBCError {
+	v, err := r.Value(col)
+	if err != nil {
+		return err
+	}
+	return convertAssign(dest, v)
+}
+
+// Scan copies the columns of the row, in order, into dest, which are
+// pointers as for database/sql: *string, *[]byte, *int64 and the other
+// numeric types, *bool, *time.Time, *interface{} or sql.Scanner. NULL
+// can only be stored in a pointer to a pointer, which is set to nil,
+// *[]byte, *interface{} or a sql.Scanner such as sql.NullString.
+func (r *Row) Scan(dest ...interface{}) *ODBCError {
+	if len(dest) != len(r.Data) {
+		return &ODBCError{SQLState: "07002", ErrorMessage: fmt.Sprintf("Scan: %d destinations for %d columns", len(dest), len(r.Data))}
+	}
+	for i, d := range dest {
+		if err := convertAssign(d, r.Data[i]); err != nil {
+			name := strconv.Itoa(i)
+			if i < len(r.fields) {
+				name = r.fields[i].Name
+			}
+			err.ErrorMessage = fmt.Sprintf("Scan column %s: %s", name, err.ErrorMessage)
+			return err
+		}
+	}
+	return nil
+}
+
+// convertAssign stores src, a value from GetField, in the location dest
+// points to.
+func convertAssign(dest, src interface{}) *ODBCError {
+	if s, ok := dest.(sql.Scanner); ok {
+		if err := s.Scan(src); err != nil {
+			return &ODBCError{SQLState: "22018", ErrorMessage: err.Error()}
+		}
+		return nil
+	}
+	switch d := dest.(type) {
+	case *interface{}:
+		*d = src
+		return nil
+	case *[]byte:
+		switch s := src.(type) {
+		case nil:
+			*d = nil
+			return nil
+		case []byte:
+			*d = append([]byte(nil), s...)
+			return nil
+		case string:
+			*d = []byte(s)
+			return nil
+		}
+	case *string:
+		switch s := src.(type) {
+		case string:
+			*d = s
+			return nil
+		case []byte:
+			*d = string(s)
+			return nil
+		case time.Time:
+			*d = s.Format(time.RFC3339Nano)
+			return nil
+		case int, int64, float64, byte:
+			*d = fmt.Sprint(s)
+			return nil
+		}
+	case *time.Time:
+		if s, ok := src.(time.Time); ok {
+			*d = s
+			return nil
+		}
+	case *bool:
+		switch s := src.(type) {
+		case byte:
+			*d = s != 0
+			return nil
+		case int:
+			*d = s != 0
+			return nil
+		case int64:
+			*d = s != 0
+			return nil
+		case string:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return &ODBCError{SQLState: "22018", ErrorMessage: err.Error()}
+			}
+			*d = b
+			return nil
+		}
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return &ODBCError{SQLState: "HY009", ErrorMessage: fmt.Sprintf("destination %T is not a non-nil pointer", dest)}
+	}
+	dv = dv.Elem()
+	if src == nil {
+		if dv.Kind() == reflect.Ptr {
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return &ODBCError{SQLState: "22002", ErrorMessage: fmt.Sprintf("NULL cannot be stored in %T", dest)}
+	}
+	if dv.Kind() == reflect.Ptr {
+		p := reflect.New(dv.Type().Elem())
+		if err := convertAssign(p.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(p)
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	var text string
+	switch s := src.(type) {
+	case string:
+		text = strings.TrimSpace(s)
+	case []byte:
+		text = strings.TrimSpace(string(s))
+	}
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int64:
+			n = sv.Int()
+		case reflect.Uint8:
+			n = int64(sv.Uint())
+		case reflect.Float64:
+			f := sv.Float()
+			if f != float64(int64(f)) {
+				return outOfRange(src, dest)
+			}
+			n = int64(f)
+		case reflect.String, reflect.Slice:
+			var err error
+			if n, err = strconv.ParseInt(text, 10, 64); err != nil {
+				return &ODBCError{SQLState: "22018", ErrorMessage: err.Error()}
+			}
+		default:
+			return cannotConvert(src, dest)
+		}
+		if dv.OverflowInt(n) {
+			return outOfRange(src, dest)
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int64:
+			if sv.Int() < 0 {
+				return outOfRange(src, dest)
+			}
+			n = uint64(sv.Int())
+		case reflect.Uint8:
+			n = sv.Uint()
+		case reflect.Float64:
+			f := sv.Float()
+			if f < 0 || f != float64(uint64(f)) {
+				return outOfRange(src, dest)
+			}
+			n = uint64(f)
+		case reflect.String, reflect.Slice:
+			var err error
+			if n, err = strconv.ParseUint(text, 10, 64); err != nil {
+				return &ODBCError{SQLState: "22018", ErrorMessage: err.Error()}
+			}
+		default:
+			return cannotConvert(src, dest)
+		}
+		if dv.OverflowUint(n) {
+			return outOfRange(src, dest)
+		}
+		dv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch sv.Kind() {
+		case reflect.Int, reflect.Int64:
+			f = float64(sv.Int())
+		case reflect.Uint8:
+			f = float64(sv.Uint())
+		case reflect.Float64:
+			f = sv.Float()
+		case reflect.String, reflect.Slice:
+			var err error
+			if f, err = strconv.ParseFloat(text, 64); err != nil {
+				return &ODBCError{SQLState: "22018", ErrorMessage: err.Error()}
+			}
+		default:
+			return cannotConvert(src, dest)
+		}
+		if dv.OverflowFloat(f) {
+			return outOfRange(src, dest)
+		}
+		dv.SetFloat(f)
+		return nil
+	case reflect.String:
+		var s string
+		if err := convertAssign(&s, src); err != nil {
+			return err
+		}
+		dv.SetString(s)
+		return nil
+	}
+	return cannotConvert(src, dest)
+}
+
+func cannotConvert(src, dest interface{}) *ODBCError {
+	return &ODBCError{SQLState: "07006", ErrorMessage: fmt.Sprintf("cannot convert %T to %T", src, dest)}
+}
+
+func outOfRange(src, dest interface{}) *ODBCError {
+	return &ODBCError{SQLState: "22003", ErrorMessage: fmt.Sprintf("value %v out of range for %T", src, dest)}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestRowScan(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := &Row{
+		Data:   []interface{}{int64(42), "text", 1.5, now, nil, []byte("raw")},
+		fields: []Field{{Name: "id"}, {Name: "name"}, {Name: "amount"}, {Name: "at"}, {Name: "note"}, {Name: "data"}},
+	}
+	var (
+		id     int32
+		name   string
+		amount float32
+		at     time.Time
+		note   *string
+		data   []byte
+	)
+	if err := row.Scan(&id, &name, &amount, &at, &note, &data); err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 || name != "text" || amount != 1.5 || !at.Equal(now) || note != nil || string(data) != "raw" {
+		t.Errorf("scanned (%v, %q, %v, %v, %v, %q)", id, name, amount, at, note, data)
+	}
+
+	var s string
+	var ns sql.NullString
+	var n8 int8
+	if err := row.Scan(&id, &name, &amount, &at, &s, &data); err == nil {
+		t.Error("no error for NULL into *string")
+	}
+	if err := row.Scan(&n8, &name, &amount, &at, &ns, &data); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Valid {
+		t.Errorf("NULL scanned as %+v", ns)
+	}
+	row.Data[0] = int64(300)
+	if err := row.Scan(&n8, &name, &amount, &at, &ns, &data); err == nil || err.SQLState != "22003" {
+		t.Errorf("300 into int8: %v, want 22003", err)
+	}
+	if err := row.Scan(&id); err == nil || err.SQLState != "07002" {
+		t.Errorf("too few destinations: %v, want 07002", err)
+	}
+}
+
+func TestRowIndex(t *testing.T) {
+	row := &Row{Data: []interface{}{1, 2}, fields: []Field{{Name: "ID"}, {Name: "id"}}}
+	for _, tt := range []struct {
+		col  interface{}
+		want int
+	}{
+		{"ID", 0}, {"id", 1}, {"Id", 0}, {1, 1}, {uint8(0), 0},
+	} {
+		if got, err := row.Index(tt.col); err != nil || got != tt.want {
+			t.Errorf("Index(%#v) = %d, %v, want %d", tt.col, got, err, tt.want)
+		}
+	}
+	for _, col := range []interface{}{"x", 2, -1, 1.0} {
+		if _, err := row.Index(col); err == nil {
+			t.Errorf("Index(%#v): no error", col)
+		}
+	}
+}
+
+// TestGetFieldNull reads NULL character and binary columns, which must
+// come back as nil rather than empty values.
+func TestGetFieldNull(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_null", "id integer, name varchar(20), wname nvarchar(20), data varbinary(20)")
+	testExec(t, conn, "insert into odbc_null values (?, ?, ?, ?)", 1, nil, nil, nil)
+	testExec(t, conn, "insert into odbc_null values (?, ?, ?, ?)", 2, "", "", []byte{0})
+
+	stmt, err := conn.ExecDirect("select name, wname, data from odbc_null order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.FetchAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	for i, v := range rows[0].Data {
+		if v != nil {
+			t.Errorf("NULL column %d read as %#v", i, v)
+		}
+	}
+	for i, v := range rows[1].Data {
+		if v == nil {
+			t.Errorf("empty column %d read as NULL", i)
+		}
+	}
+
+	var s *string
+	var ns sql.NullString
+	var ws *string
+	var wns sql.NullString
+	var b []byte
+	if err := rows[0].Scan(&s, &ws, &b); err != nil {
+		t.Fatal(err)
+	}
+	if s != nil || ws != nil || b != nil {
+		t.Errorf("NULL scanned as (%v, %v, %v)", s, ws, b)
+	}
+	if err := rows[0].Scan(&ns, &wns, &b); err != nil {
+		t.Fatal(err)
+	}
+	if ns.Valid || wns.Valid {
+		t.Errorf("NULL scanned as (%+v, %+v)", ns, wns)
+	}
+	if err := rows[1].Scan(&ns, &wns, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !ns.Valid || !wns.Valid {
+		t.Errorf("empty string scanned as (%+v, %+v)", ns, wns)
+	}
+}
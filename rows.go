@@ -0,0 +1,158 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+// Rows streams the rows of an executed statement, one at a time:
+//
+//	rows := stmt.Rows()
+//	defer rows.Close()
+//	for rows.Next() {
+//		row := rows.Row()
+//		...
+//	}
+//	if err := rows.Err(); err != nil {
+//		...
+//	}
+type Rows struct {
+	stmt *Statement
+	row  *Row
+	err  *ODBCError
+	done bool // Next returns false
+	open bool // the cursor still needs closing
+
+	maxRows  int
+	maxBytes int64
+	rows     int
+	bytes    int64
+}
+
+// Rows returns an iterator over the remaining rows of the current result
+// set of stmt.
+func (stmt *Statement) Rows() *Rows {
+	return &Rows{stmt: stmt, open: true}
+}
+
+// SetMaxRows makes Next fail with an error matching ErrLimitExceeded
+// when the result has more than n rows, before reading the columns of
+// the extra row. Zero means no limit.
+func (r *Rows) SetMaxRows(n int) {
+	r.maxRows = n
+}
+
+// SetMaxBytes makes Next fail with an error matching ErrLimitExceeded
+// once the values fetched add up to more than n bytes, counting strings
+// and byte slices by length and other values as 8 bytes. Zero means no
+// limit. The size is checked after each row has been read, so a single
+// large row is held in memory in full before the error.
+func (r *Rows) SetMaxBytes(n int64) {
+	r.maxBytes = n
+}
+
+// Next fetches the next row. It returns false at the end of the result
+// set, on error, or once Close has been called; check Err afterwards.
+func (r *Rows) Next() bool {
+	if r.done {
+		return false
+	}
+	ok, err := r.stmt.Fetch()
+	if err != nil {
+		r.fail(err)
+		return false
+	}
+	if !ok {
+		r.err = r.Close()
+		return false
+	}
+	r.rows++
+	if r.maxRows > 0 && r.rows > r.maxRows {
+		r.fail(&ODBCError{SQLState: "54000", ErrorMessage: fmt.Sprintf("result has more than %d rows", r.maxRows)})
+		return false
+	}
+	row, err := r.stmt.currentRow()
+	if err != nil {
+		r.fail(err)
+		return false
+	}
+	r.bytes += rowBytes(row)
+	if r.maxBytes > 0 && r.bytes > r.maxBytes {
+		r.fail(&ODBCError{SQLState: "54000", ErrorMessage: fmt.Sprintf("result is larger than %d bytes", r.maxBytes)})
+		return false
+	}
+	r.row = row
+	return true
+}
+
+// fail ends the iteration with err, keeping the records of any error
+// from closing the cursor.
+func (r *Rows) fail(err *ODBCError) {
+	if cerr := r.Close(); cerr != nil {
+		err.Records = append(err.Records, cerr.Records...)
+		err.ErrorMessage += cerr.ErrorMessage
+	}
+	r.err = err
+}
+
+// Row returns the row fetched by the last successful Next.
+func (r *Rows) Row() *Row {
+	return r.row
+}
+
+// Err returns the error that ended the iteration, if any.
+func (r *Rows) Err() *ODBCError {
+	return r.err
+}
+
+// Close discards the rest of the result set by closing the cursor
+// (SQLFreeStmt with SQL_CLOSE). The statement stays prepared and can be
+// executed again. Only the first call closes the cursor, whether Next
+// reached the end of the result set or not.
+func (r *Rows) Close() *ODBCError {
+	r.done = true
+	r.row = nil
+	if !r.open {
+		return nil
+	}
+	r.open = false
+	ret := C.SQLFreeStmt(C.SQLHSTMT(r.stmt.handle), C.SQL_CLOSE)
+	if !Success(ret) {
+		err := FormatError(C.SQL_HANDLE_STMT, r.stmt.handle)
+		return err
+	}
+	return nil
+}
+
+func rowBytes(row *Row) int64 {
+	var n int64
+	for _, v := range row.Data {
+		switch v := v.(type) {
+		case string:
+			n += int64(len(v))
+		case []byte:
+			n += int64(len(v))
+		case time.Time:
+			n += 24
+		default:
+			n += 8
+		}
+	}
+	return n
+}
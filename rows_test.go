@@ -0,0 +1,140 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testNumbers(t *testing.T) *Statement {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_rows", "id integer, name varchar(20)")
+	for i := 1; i <= 5; i++ {
+		testExec(t, conn, "insert into odbc_rows values (?, ?)", i, strings.Repeat("x", 10))
+	}
+	stmt, err := conn.Prepare("select id, name from odbc_rows order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stmt.Close() })
+	return stmt
+}
+
+// testRows counts the rows Next returns.
+func testRows(t *testing.T, rows *Rows) int {
+	n := 0
+	for rows.Next() {
+		n++
+		if id, err := rows.Row().Int(0); err != nil || id != int64(n) {
+			t.Fatalf("row %d has id %d, %v", n, id, err)
+		}
+	}
+	return n
+}
+
+func TestRowsLimits(t *testing.T) {
+	stmt := testNumbers(t)
+	for _, tt := range []struct {
+		rows  int
+		bytes int64
+		want  int
+		fail  bool
+	}{
+		{0, 0, 5, false},
+		{5, 0, 5, false},
+		{4, 0, 4, true},
+		{0, 90, 5, false},
+		{0, 89, 4, true},
+		{0, 17, 0, true},
+	} {
+		if err := stmt.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		rows := stmt.Rows()
+		rows.SetMaxRows(tt.rows)
+		rows.SetMaxBytes(tt.bytes)
+		n := testRows(t, rows)
+		err := rows.Err()
+		if n != tt.want || (err != nil) != tt.fail {
+			t.Errorf("max %d rows, %d bytes: %d rows, %v", tt.rows, tt.bytes, n, err)
+		}
+		if tt.fail && !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("max %d rows, %d bytes: %v, want ErrLimitExceeded", tt.rows, tt.bytes, err)
+		}
+		if err := rows.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestRowsReexecute runs the statement again after each way of ending
+// an iteration; a cursor left open would fail with 24000.
+func TestRowsReexecute(t *testing.T) {
+	stmt := testNumbers(t)
+	for i, end := range []func(rows *Rows){
+		func(rows *Rows) { // to the end, without Close
+			testRows(t, rows)
+		},
+		func(rows *Rows) { // to the end, then Close twice
+			testRows(t, rows)
+			rows.Close()
+			rows.Close()
+		},
+		func(rows *Rows) { // early break
+			rows.Next()
+			rows.Next()
+			rows.Close()
+		},
+		func(rows *Rows) { // Close before Next
+			rows.Close()
+			if rows.Next() {
+				t.Error("Next after Close")
+			}
+		},
+	} {
+		if err := stmt.Execute(); err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		rows := stmt.Rows()
+		end(rows)
+		if err := rows.Err(); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+	}
+	if err := stmt.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.FetchAll()
+	if err != nil || len(rows) != 5 {
+		t.Errorf("FetchAll: %d rows, %v", len(rows), err)
+	}
+}
+
+// TestFetchAllError checks that a failing fetch is reported: the
+// overflow is raised by Execute or by the first fetch, depending on the
+// driver.
+func TestFetchAllError(t *testing.T) {
+	conn := testConn(t)
+	const query = "select abs(-9223372036854775807 - 1)"
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if err := stmt.Execute(); err == nil {
+		if _, err := stmt.FetchAll(); err == nil {
+			t.Errorf("%s: no error from FetchAll", query)
+		}
+	}
+	if err := stmt.Execute(); err == nil {
+		rows := stmt.Rows()
+		if rows.Next() || rows.Err() == nil {
+			t.Errorf("%s: no error from Rows", query)
+		}
+		rows.Close()
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField is a struct field that can receive a column.
+type structField struct {
+	name  string // from the odbc tag, else the field name
+	index []int
+}
+
+// structFields caches the structField lists by struct type.
+var structFields sync.Map
+
+// fieldsOf returns the fields of struct type t that columns map to,
+// including those of embedded structs. As for encoding/json, a field
+// hides the fields of the same name deeper in embedded structs, and a
+// struct embedded in itself through a pointer is not walked again.
+func fieldsOf(t reflect.Type) []structField {
+	if fields, ok := structFields.Load(t); ok {
+		return fields.([]structField)
+	}
+	var fields []structField
+	depth := map[string]int{}
+	onPath := map[reflect.Type]bool{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		onPath[t] = true
+		defer delete(onPath, t)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("odbc")
+			if tag == "-" {
+				continue
+			}
+			idx := append(append([]int(nil), index...), i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+				// An unexported embedded pointer cannot be allocated.
+				if (sf.PkgPath == "" || sf.Type.Kind() != reflect.Ptr) && !onPath[ft] {
+					walk(ft, idx)
+				}
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue // unexported
+			}
+			name := tag
+			if name == "" {
+				name = sf.Name
+			}
+			fields = append(fields, structField{name: name, index: idx})
+			key := strings.ToLower(name)
+			if d, ok := depth[key]; !ok || len(idx) < d {
+				depth[key] = len(idx)
+			}
+		}
+	}
+	walk(t, nil)
+	visible := fields[:0]
+	for _, f := range fields {
+		key := strings.ToLower(f.name)
+		if depth[key] == len(f.index) {
+			visible = append(visible, f)
+			depth[key] = -1 // the first of that depth wins
+		}
+	}
+	fields = visible
+	structFields.Store(t, fields)
+	return fields
+}
+
+// planKey identifies a struct plan: the struct type and the names of
+// the columns, joined by NUL.
+type planKey struct {
+	t       reflect.Type
+	columns string
+}
+
+// structPlans caches the plans of structPlan by planKey.
+var structPlans sync.Map
+
+// structPlan maps each column of fields to the index of a field of
+// struct type t, or nil for columns without one. Names match exactly,
+// or else case-insensitively.
+func structPlan(t reflect.Type, fields []Field) [][]int {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	key := planKey{t, strings.Join(names, "\x00")}
+	if plan, ok := structPlans.Load(key); ok {
+		return plan.([][]int)
+	}
+	plan := newStructPlan(t, fields)
+	structPlans.Store(key, plan)
+	return plan
+}
+
+func newStructPlan(t reflect.Type, fields []Field) [][]int {
+	sfs := fieldsOf(t)
+	plan := make([][]int, len(fields))
+	for i, f := range fields {
+		for _, sf := range sfs {
+			if sf.name == f.Name {
+				plan[i] = sf.index
+				break
+			}
+		}
+		if plan[i] != nil {
+			continue
+		}
+		for _, sf := range sfs {
+			if strings.EqualFold(sf.name, f.Name) {
+				plan[i] = sf.index
+				break
+			}
+		}
+	}
+	return plan
+}
+
+// FetchStruct fetches the next row into the struct dst points to. Each
+// column is stored in the field tagged with its name, as in
+//
+//	type User struct {
+//		ID   int64          `odbc:"user_id"`
+//		Name sql.NullString `odbc:"user_name"`
+//		Note *string        // column "note"
+//	}
+//
+// or else in the field of that name, compared case-insensitively.
+// Fields of embedded structs are included, a tag of "-" skips a field,
+// and columns without a field are ignored. Values are converted as by
+// Row.Scan, so NULL needs a pointer or sql.Null* field. It returns false
+// when there are no more rows.
+func (stmt *Statement) FetchStruct(dst interface{}) (bool, *ODBCError) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return false, &ODBCError{SQLState: "HY009", ErrorMessage: fmt.Sprintf("FetchStruct: %T is not a pointer to a struct", dst)}
+	}
+	row, err := stmt.FetchOne()
+	if row == nil {
+		return false, err
+	}
+	plan := structPlan(v.Elem().Type(), row.fields)
+	if err := row.scanStruct(v.Elem(), plan); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FetchAllStructs fetches the remaining rows and appends them to the
+// slice slicePtr points to, whose elements are structs or pointers to
+// structs mapped as for FetchStruct.
+func (stmt *Statement) FetchAllStructs(slicePtr interface{}) *ODBCError {
+	v := reflect.ValueOf(slicePtr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return &ODBCError{SQLState: "HY009", ErrorMessage: fmt.Sprintf("FetchAllStructs: %T is not a pointer to a slice", slicePtr)}
+	}
+	slice := v.Elem()
+	et := slice.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	if isPtr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return &ODBCError{SQLState: "HY009", ErrorMessage: fmt.Sprintf("FetchAllStructs: %T is not a slice of structs", slicePtr)}
+	}
+	var plan [][]int
+	for {
+		row, err := stmt.FetchOne()
+		if row == nil {
+			return err
+		}
+		if plan == nil {
+			plan = structPlan(et, row.fields)
+		}
+		elem := reflect.New(et)
+		if err := row.scanStruct(elem.Elem(), plan); err != nil {
+			return err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+}
+
+func (r *Row) scanStruct(v reflect.Value, plan [][]int) *ODBCError {
+	for i, index := range plan {
+		if index == nil {
+			continue
+		}
+		f := fieldByIndex(v, index)
+		if err := convertAssign(f.Addr().Interface(), r.Data[i]); err != nil {
+			err.ErrorMessage = fmt.Sprintf("column %s: %s", r.fields[i].Name, err.ErrorMessage)
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but allocates nil
+// embedded struct pointers on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+// Person is exported so that the embedded pointer to it can be set.
+type Person struct {
+	ID   int64
+	Name string `odbc:"user_name"`
+}
+
+// Node embeds itself, see TestStructCycle.
+type Node struct {
+	*Node
+	ID int64
+}
+
+type testInner struct {
+	Deep string
+}
+
+type testUser struct {
+	*Person
+	testInner
+	Nick sql.NullString `odbc:"nick"`
+	Note *string
+	Skip int `odbc:"-"`
+	ID   int32
+}
+
+func testFields(names ...string) []Field {
+	fields := make([]Field, len(names))
+	for i, name := range names {
+		fields[i].Name = name
+	}
+	return fields
+}
+
+func TestScanStruct(t *testing.T) {
+	row := &Row{
+		Data:   []interface{}{int64(7), "bob", nil, "note", int64(3), "deep", "extra"},
+		fields: testFields("id", "user_name", "nick", "NOTE", "skip", "deep", "extra"),
+	}
+	var u testUser
+	plan := structPlan(reflect.TypeOf(u), row.fields)
+	if err := row.scanStruct(reflect.ValueOf(&u).Elem(), plan); err != nil {
+		t.Fatal(err)
+	}
+	// The shallower ID hides Person.ID.
+	if u.ID != 7 || u.Person == nil || u.Person.ID != 0 || u.Name != "bob" {
+		t.Errorf("scanned %+v, %+v", u, u.Person)
+	}
+	if u.Nick.Valid || u.Note == nil || *u.Note != "note" || u.Skip != 0 || u.Deep != "deep" {
+		t.Errorf("scanned %+v", u)
+	}
+
+	// NULL needs a pointer or a sql.Null* field.
+	row.Data[1] = nil
+	if err := row.scanStruct(reflect.ValueOf(&u).Elem(), plan); err == nil || err.SQLState != "22002" {
+		t.Errorf("NULL into a string field: %v, want 22002", err)
+	}
+}
+
+func TestStructPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(testUser{})
+	a := structPlan(typ, testFields("id", "nick"))
+	b := structPlan(typ, testFields("id", "nick"))
+	if &a[0] != &b[0] {
+		t.Error("plan rebuilt for the same type and columns")
+	}
+	c := structPlan(typ, testFields("nick", "id"))
+	if reflect.DeepEqual(a, c) {
+		t.Error("plan reused for other columns")
+	}
+	if !reflect.DeepEqual(a[0], c[1]) || !reflect.DeepEqual(a[1], c[0]) {
+		t.Errorf("plans %v and %v do not match", a, c)
+	}
+}
+
+func TestStructCycle(t *testing.T) {
+	fields := fieldsOf(reflect.TypeOf(Node{}))
+	if len(fields) != 1 || fields[0].name != "ID" || !reflect.DeepEqual(fields[0].index, []int{1}) {
+		t.Errorf("fields of Node: %+v", fields)
+	}
+	row := &Row{Data: []interface{}{int64(5)}, fields: testFields("id")}
+	var n Node
+	if err := row.scanStruct(reflect.ValueOf(&n).Elem(), structPlan(reflect.TypeOf(n), row.fields)); err != nil {
+		t.Fatal(err)
+	}
+	if n.ID != 5 || n.Node != nil {
+		t.Errorf("scanned %+v", n)
+	}
+}
+
+// TestFetchStructNull fetches NULL character columns into pointer and
+// sql.NullString fields.
+func TestFetchStructNull(t *testing.T) {
+	conn := testConn(t)
+	testTable(t, conn, "odbc_struct", "id integer, nick varchar(20), note nvarchar(20)")
+	testExec(t, conn, "insert into odbc_struct values (?, ?, ?)", 1, "bob", "hi")
+	testExec(t, conn, "insert into odbc_struct values (?, ?, ?)", 2, nil, nil)
+
+	stmt, err := conn.ExecDirect("select id, nick, note from odbc_struct order by id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var users []*testUser
+	if err := stmt.FetchAllStructs(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("%d rows, want 2", len(users))
+	}
+	if u := users[0]; u.ID != 1 || u.Nick.String != "bob" || u.Note == nil || *u.Note != "hi" {
+		t.Errorf("row 1 is %+v", u)
+	}
+	if u := users[1]; u.ID != 2 || u.Nick.Valid || u.Note != nil {
+		t.Errorf("row 2 is %+v", u)
+	}
+}
@@ -1,6 +1,7 @@
 package odbc
 
 import (
+	"encoding/binary"
 	"unicode/utf16"
 )
 
@@ -23,3 +24,53 @@ func UTF16ToString(s []uint16) string {
 // StringToUTF16Ptr returns pointer to the UTF-16 encoding of
 // the UTF-8 string s, with a terminating NUL added.
 func StringToUTF16Ptr(s string) *uint16 { return &StringToUTF16(s)[0] }
+
+// wcharSize is the size in bytes of SQLWCHAR for the driver manager in
+// use: 2 where it is UTF-16 (unixODBC, Windows), 4 where it is a UTF-32
+// wchar_t (iODBC).
+var wcharSize = 2
+
+// stringToWide returns the SQLWCHAR encoding of the UTF-8 string s, with
+// a terminating NUL added.
+func stringToWide(s string) []byte {
+	if wcharSize == 4 {
+		r := []rune(s + "\x00")
+		b := make([]byte, len(r)*4)
+		for i, c := range r {
+			binary.NativeEndian.PutUint32(b[i*4:], uint32(c))
+		}
+		return b
+	}
+	u := StringToUTF16(s)
+	b := make([]byte, len(u)*2)
+	for i, c := range u {
+		binary.NativeEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+// wideToString returns the UTF-8 encoding of the SQLWCHAR sequence b,
+// up to the first NUL.
+func wideToString(b []byte) string {
+	if wcharSize == 4 {
+		r := make([]rune, 0, len(b)/4)
+		for i := 0; i+4 <= len(b); i += 4 {
+			c := binary.NativeEndian.Uint32(b[i:])
+			if c == 0 {
+				break
+			}
+			r = append(r, rune(c))
+		}
+		return string(r)
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.NativeEndian.Uint16(b[i*2:])
+	}
+	return UTF16ToString(u)
+}
+
+// wideBuffer returns a zeroed buffer for n SQLWCHARs.
+func wideBuffer(n int) []byte {
+	return make([]byte, n*wcharSize)
+}
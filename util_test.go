@@ -0,0 +1,57 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWideStrings(t *testing.T) {
+	defer func(n int) { wcharSize = n }(wcharSize)
+	tests := []struct {
+		s      string
+		units2 int // UTF-16 code units, without the NUL
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"héllo", 5},
+		{"日本語", 3},
+		{"a😀b", 4}, // U+1F600 takes a surrogate pair
+		{"𝄞𠜎", 4},  // U+1D11E, U+2070E
+	}
+	for _, size := range []int{2, 4} {
+		wcharSize = size
+		for _, tt := range tests {
+			units := tt.units2
+			if size == 4 {
+				units = len([]rune(tt.s))
+			}
+			b := stringToWide(tt.s)
+			if len(b) != (units+1)*size {
+				t.Errorf("size %d, %q: %d bytes, want %d", size, tt.s, len(b), (units+1)*size)
+			}
+			if !bytes.Equal(b[len(b)-size:], make([]byte, size)) {
+				t.Errorf("size %d, %q: no terminating NUL in % x", size, tt.s, b)
+			}
+			if got := wideToString(b); got != tt.s {
+				t.Errorf("size %d: %q read back as %q", size, tt.s, got)
+			}
+			// Without the NUL, and with garbage after it.
+			if got := wideToString(b[:len(b)-size]); got != tt.s {
+				t.Errorf("size %d: %q without NUL read back as %q", size, tt.s, got)
+			}
+			if got := wideToString(append(b, stringToWide("junk")...)); got != tt.s {
+				t.Errorf("size %d: %q followed by junk read back as %q", size, tt.s, got)
+			}
+		}
+		if n := len(wideBuffer(10)); n != 10*size {
+			t.Errorf("size %d: wideBuffer(10) has %d bytes", size, n)
+		}
+		if got := wideToString(make([]byte, 3*size+1)); got != "" {
+			t.Errorf("size %d: zeroed buffer read as %q", size, got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2011, Wei guangjing <vcc.163@gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package odbc
+
+/*
+#ifdef __MINGW32__
+  #include <windef.h>
+#else
+  typedef void* HANDLE;
+#endif
+
+#include <sql.h>
+#include <sqlext.h>
+#include <sqltypes.h>
+*/
+import "C"
+
+// MAX_WARNINGS is the number of warnings a connection or statement keeps
+// for Warnings. Later ones are only passed to the WarningHandler, so that
+// a long result set whose rows each raise a warning does not pile them
+// up in memory.
+const MAX_WARNINGS = 100
+
+// WarningHandler receives the diagnostic records of calls that returned
+// SQL_SUCCESS_WITH_INFO, such as SQL Server PRINT output or low severity
+// RAISERROR messages, as they happen.
+type WarningHandler func(rec DiagRecord)
+
+// SetWarningHandler installs h for the connection and for the
+// statements allocated on it afterwards. A nil h removes the handler.
+func (conn *Connection) SetWarningHandler(h WarningHandler) {
+	conn.warningHandler = h
+}
+
+// Warnings returns the first MAX_WARNINGS warnings collected on the
+// connection itself, for instance while connecting or ending a
+// transaction.
+func (conn *Connection) Warnings() []DiagRecord {
+	return conn.warnings
+}
+
+func (conn *Connection) ClearWarnings() {
+	conn.warnings = nil
+}
+
+func (conn *Connection) checkInfo(ret C.SQLRETURN) {
+	if ret == C.SQL_SUCCESS_WITH_INFO {
+		conn.warnings = appendWarnings(conn.warnings, conn.warningHandler, C.SQL_HANDLE_DBC, conn.Dbc)
+	}
+}
+
+// SetWarningHandler installs h for the statement. A nil h removes the
+// handler.
+func (stmt *Statement) SetWarningHandler(h WarningHandler) {
+	stmt.warningHandler = h
+}
+
+// Warnings returns the first MAX_WARNINGS warnings collected since the
+// statement was last executed, including those of Fetch and NextResult.
+func (stmt *Statement) Warnings() []DiagRecord {
+	return stmt.warnings
+}
+
+func (stmt *Statement) ClearWarnings() {
+	stmt.warnings = nil
+}
+
+func (stmt *Statement) checkInfo(ret C.SQLRETURN) {
+	if ret == C.SQL_SUCCESS_WITH_INFO {
+		stmt.warnings = appendWarnings(stmt.warnings, stmt.warningHandler, C.SQL_HANDLE_STMT, stmt.handle)
+	}
+}
+
+func appendWarnings(warnings []DiagRecord, h WarningHandler, ht C.SQLSMALLINT, handle C.SQLHANDLE) []DiagRecord {
+	recs := FormatError(ht, handle).Records
+	if h != nil {
+		for _, rec := range recs {
+			h(rec)
+		}
+	}
+	if n := MAX_WARNINGS - len(warnings); len(recs) > n {
+		recs = recs[:n]
+	}
+	return append(warnings, recs...)
+}